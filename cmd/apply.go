@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/apply"
+	"github.com/spf13/cobra"
+)
+
+var ApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Reconciles organization variables with a declarative YAML file",
+	Long:  "Reconciles organization, repository, and environment variables with a declarative YAML file. A plan of the changes is printed before anything is applied.",
+	Run: func(cmd *cobra.Command, args []string) {
+		BindFlags(cmd, "prune", "dry-run", "auto-approve")
+		GetFlagOrViperValue(cmd, map[string]bool{
+			"file":                true,
+			"target-hostname":     false,
+			"target-organization": false,
+			"target-token":        true,
+		})
+		ShowConnectionStatus("apply")
+		if err := apply.ApplyDesiredState(); err != nil {
+			fmt.Printf("failed to apply variables: %v\n", err)
+			logging.Exit(1)
+		}
+		return
+	},
+}
+
+func init() {
+	// Add flags to the ApplyCmd
+	ApplyCmd.Flags().StringP("file", "f", "", "Desired state YAML file (required)")
+	ApplyCmd.Flags().StringP("target-hostname", "n", "", "GitHub Enterprise Server hostname URL (optional) Ex. https://github.example.com")
+	ApplyCmd.Flags().StringP("target-organization", "o", "", "Organization to manage (defaults to the organization in the file)")
+	ApplyCmd.Flags().StringP("target-token", "t", "", "GitHub token or token source: gh, file:<path>, fd:<n>, or cmd:<command> (required)")
+	ApplyCmd.Flags().Bool("prune", false, "Delete variables that are not declared, limited to the scopes declared in the file")
+	ApplyCmd.Flags().Bool("dry-run", false, "Print the plan without applying it")
+	ApplyCmd.Flags().Bool("auto-approve", false, "Apply the plan without asking for confirmation")
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/audit"
+	"github.com/spf13/cobra"
+)
+
+var AuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Reports which exported variables are used by workflows",
+	Long:  "Scans the .github/workflows files of every repository in an organization, through the API or in local clones, for vars.NAME references and compares them with an export CSV. Reports unused variables, references to variables that do not exist, and organization variables shadowed by repository variables.",
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrViperValue(cmd, map[string]bool{
+			"source-hostname":     false,
+			"source-organization": true,
+			"source-token":        false,
+			"file":                true,
+			"workflows-dir":       false,
+			"report-file":         false,
+		})
+		ShowConnectionStatus("audit")
+		if err := audit.RunAudit(); err != nil {
+			fmt.Printf("failed to audit variables: %v\n", err)
+			logging.Exit(1)
+		}
+		return
+	},
+}
+
+func init() {
+	// Add flags to the AuditCmd. The connection flags are read through GetFlagOrViperValue, which
+	// also falls back to the GHMV_ environment variables the export command uses.
+	AuditCmd.Flags().StringP("source-hostname", "n", "", "GitHub Enterprise Server hostname (optional) Ex. github.example.com")
+	AuditCmd.Flags().StringP("source-organization", "o", "", "Organization to audit (required)")
+	AuditCmd.Flags().StringP("source-token", "t", "", "GitHub token or token source: gh, file:<path>, fd:<n>, or cmd:<command> (required unless --workflows-dir is set)")
+	AuditCmd.Flags().StringP("file", "f", "", "Variables CSV written by export (required)")
+	AuditCmd.Flags().String("workflows-dir", "", "Directory with a local clone of each repository, <dir>/<repository>/.github/workflows, instead of reading workflows through the API")
+	AuditCmd.Flags().String("report-file", "", "Audit report CSV file (default <organization>_variable_audit.csv)")
+}
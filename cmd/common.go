@@ -3,8 +3,15 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/mona-actions/gh-migrate-variables/pkg/wizard"
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -16,7 +23,7 @@ func GetFlagOrViperValue(cmd *cobra.Command, flags map[string]bool) map[string]s
 	for name, required := range flags {
 		envName := "GHMV_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
 
-		flagVal, _ := cmd.Flags().GetString(name)
+		flagVal := getFlagString(cmd, name)
 		kebabVal := viper.GetString(name)
 		prefixedVal := viper.GetString(envName)
 
@@ -30,40 +37,161 @@ func GetFlagOrViperValue(cmd *cobra.Command, flags map[string]bool) map[string]s
 		}
 
 		if value != "" {
+			if err := checkTokenSource(cmd, name, value); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				logging.Exit(1)
+			}
 			viper.Set(name, value)
 			viper.Set(envName, value)
 			values[name] = value
-		} else if required {
+		} else if required || (wizard.Enabled() && strings.HasSuffix(name, "-hostname")) {
 			missing = append(missing, name)
 		}
 	}
 
+	// In interactive mode missing connection values are prompted for instead of failing
+	if len(missing) > 0 && wizard.Enabled() {
+		if err := wizard.Check(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			logging.Exit(1)
+		}
+		sort.Strings(missing)
+		var stillMissing []string
+		for _, name := range missing {
+			value, err := wizard.PromptValue(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				logging.Exit(1)
+			}
+			if value == "" {
+				if flags[name] {
+					stillMissing = append(stillMissing, name)
+				}
+				continue
+			}
+			envName := "GHMV_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+			viper.Set(name, value)
+			viper.Set(envName, value)
+			values[name] = value
+		}
+		missing = stillMissing
+	}
+
 	if len(missing) > 0 {
 		fmt.Fprintf(os.Stderr, "Error: missing required values: %s\n", strings.Join(missing, ", "))
-		os.Exit(1)
+		logging.Exit(1)
 	}
 
 	return values
 }
 
+// Refuses a cmd: token source that did not come from the command line: the token flag, or a
+// profile selected with its flag. The .env file is read from the working directory, so a cloned
+// repository could otherwise run a command.
+func checkTokenSource(cmd *cobra.Command, name, value string) error {
+	if !strings.HasSuffix(name, "-token") || !strings.HasPrefix(value, api.TokenSourceCommand) {
+		return nil
+	}
+	if explicit, fromProfile := profileTokens[name]; fromProfile {
+		if explicit {
+			return nil
+		}
+		prefix := strings.TrimSuffix(name, "-token")
+		return fmt.Errorf("%s: cmd: token sources in a profile are only accepted when the profile is selected with --%s-profile", name, prefix)
+	}
+	if cmd.Flags().Changed(name) {
+		return nil
+	}
+	return fmt.Errorf("%s: cmd: token sources are only accepted from --%s or a config file profile, not from the environment or a .env file", name, name)
+}
+
+// Binds boolean and other non-string flags to their GHMV_ prefixed viper keys. Flags that
+// several commands share are bound when the command runs, since viper keeps one binding per key.
+func BindFlags(cmd *cobra.Command, names ...string) {
+	for _, name := range names {
+		envName := "GHMV_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		viper.BindPFlag(envName, cmd.Flags().Lookup(name))
+	}
+}
+
+// Returns the flag value as a string, joining list flags with commas
+func getFlagString(cmd *cobra.Command, name string) string {
+	flag := cmd.Flags().Lookup(name)
+	if flag == nil {
+		return ""
+	}
+	if flag.Value.Type() == "stringSlice" {
+		values, _ := cmd.Flags().GetStringSlice(name)
+		return strings.Join(values, ",")
+	}
+	value, _ := cmd.Flags().GetString(name)
+	return value
+}
+
 func ShowConnectionStatus(actionType string) {
 	var endpoint string // Declare endpoint once
 
 	// Determine the endpoint based on action type
 	switch actionType {
-	case "export", "pull":
+	case "export", "pull", "audit":
 		endpoint = "source-hostname"
-	case "sync":
+	case "sync", "rollback", "apply":
 		endpoint = "target-hostname"
 	}
 
 	hostname := getNormalizedEndpoint(endpoint)
-	httpProxy := viper.GetString("HTTP_PROXY")
-	httpsProxy := viper.GetString("HTTPS_PROXY")
 
 	// Print status information
-	fmt.Println(getHostnameMessage(hostname))
-	fmt.Println(getProxyStatus(httpProxy, httpsProxy))
+	output.Message(getHostnameMessage(hostname))
+	output.Message(getProxyStatus(hostname))
+
+	if err := configureEndpoint(strings.TrimSuffix(endpoint, "-hostname"), hostname); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		logging.Exit(1)
+	}
+}
+
+// Registers the TLS and connection settings of the source or target endpoint with the API client
+func configureEndpoint(prefix, hostname string) error {
+	key := "GHMV_" + strings.ToUpper(prefix) + "_"
+	config := api.EndpointConfig{
+		CABundle:           viper.GetString(key + "CA_BUNDLE"),
+		ClientCert:         viper.GetString(key + "CLIENT_CERT"),
+		ClientKey:          viper.GetString(key + "CLIENT_KEY"),
+		MinTLSVersion:      viper.GetString(key + "MIN_TLS_VERSION"),
+		InsecureSkipVerify: viper.GetBool(key + "INSECURE_SKIP_VERIFY"),
+		RetryMax:           viper.GetInt(key + "RETRY_MAX"),
+		Backoff:            viper.GetString(key + "BACKOFF"),
+		MaxIdleConns:       viper.GetInt(key + "MAX_IDLE_CONNS"),
+	}
+	durations := []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"timeout", &config.CallTimeout},
+		{"retry-budget", &config.RetryBudget},
+		{"retry-delay", &config.RetryDelay},
+		{"retry-max-delay", &config.RetryMaxDelay},
+	}
+	for _, duration := range durations {
+		value := viper.GetString(key + strings.ToUpper(strings.ReplaceAll(duration.name, "-", "_")))
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid --%s-%s %q: %w", prefix, duration.name, value, err)
+		}
+		*duration.target = parsed
+	}
+	if err := api.ConfigureEndpoint(prefix, hostname, viper.GetString(prefix+"-token"), config); err != nil {
+		return fmt.Errorf("invalid connection settings for the %s instance: %w", prefix, err)
+	}
+	if config.InsecureSkipVerify {
+		pterm.Warning.Printf("TLS CERTIFICATE VERIFICATION IS DISABLED for the %s instance (--%s-insecure-skip-verify).\n", prefix, prefix)
+		pterm.Warning.Println("Anyone on the network path can intercept the token and variable values. Use --" + prefix + "-ca-bundle instead outside of testing.")
+	}
+	return nil
 }
 
 func getNormalizedEndpoint(key string) string {
@@ -79,16 +207,26 @@ func getNormalizedEndpoint(key string) string {
 	return hostname
 }
 
-func getHostnameMessage(hostname string) string {
+// Returns the icon and message naming the instance a command connects to
+func getHostnameMessage(hostname string) (string, string) {
 	if hostname != "" {
-		return fmt.Sprintf("\n🔗 Using: GitHub Enterprise Server: %s", hostname)
+		return "🔗", fmt.Sprintf("Using: GitHub Enterprise Server: %s", hostname)
 	}
-	return "\n📡 Using: GitHub.com"
+	return "📡", "Using: GitHub.com"
 }
 
-func getProxyStatus(httpProxy, httpsProxy string) string {
-	if httpProxy != "" || httpsProxy != "" {
-		return "🔄 Proxy: ✅ Configured\n"
+// Returns the icon and message describing the proxy that requests to the endpoint actually go
+// through
+func getProxyStatus(hostname string) (string, string) {
+	proxyURL, err := api.ProxyForEndpoint(hostname)
+	if err != nil {
+		return "⚠️ ", fmt.Sprintf("Proxy: %v", err)
+	}
+	if proxyURL != nil {
+		return "🔄", fmt.Sprintf("Proxy: %s", proxyURL.Redacted())
+	}
+	if viper.GetString("HTTP_PROXY") != "" || viper.GetString("HTTPS_PROXY") != "" {
+		return "⏭️ ", "Proxy: bypassed by NO_PROXY"
 	}
-	return "🔄 Proxy: ❌ Not configured\n"
+	return "🔄", "Proxy: not configured"
 }
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Profile holds the connection settings and flag defaults for one GitHub instance
+type Profile struct {
+	Hostname     string `mapstructure:"hostname"`
+	Organization string `mapstructure:"organization"`
+	Token        string `mapstructure:"token"`
+	// TokenEnv names an environment variable holding the token, so the token can stay out of the file
+	TokenEnv string `mapstructure:"token-env"`
+	Proxy    struct {
+		HTTP     string `mapstructure:"http"`
+		HTTPS    string `mapstructure:"https"`
+		NoProxy  string `mapstructure:"no-proxy"`
+		Username string `mapstructure:"username"`
+		Password string `mapstructure:"password"`
+	} `mapstructure:"proxy"`
+	TLS struct {
+		CABundle           string `mapstructure:"ca-bundle"`
+		ClientCert         string `mapstructure:"client-cert"`
+		ClientKey          string `mapstructure:"client-key"`
+		MinVersion         string `mapstructure:"min-version"`
+		InsecureSkipVerify string `mapstructure:"insecure-skip-verify"`
+	} `mapstructure:"tls"`
+	Timeout      string `mapstructure:"timeout"`
+	MaxIdleConns string `mapstructure:"max-idle-conns"`
+	Retry        struct {
+		Max      string `mapstructure:"max"`
+		Delay    string `mapstructure:"delay"`
+		MaxDelay string `mapstructure:"max-delay"`
+		Budget   string `mapstructure:"budget"`
+		Backoff  string `mapstructure:"backoff"`
+	} `mapstructure:"retry"`
+	// Defaults sets any other flag of the command being run, keyed by flag name
+	Defaults map[string]string `mapstructure:"defaults"`
+}
+
+// ConfigFile is the layout of the YAML or TOML config file
+type ConfigFile struct {
+	Profiles map[string]Profile `mapstructure:"profiles"`
+}
+
+// profileTokens records the token flags set from a profile, and whether that profile was selected
+// with --source-profile or --target-profile rather than through the environment
+var profileTokens = make(map[string]bool)
+
+// Returns a setting that selects which config file and profiles are read. It only comes from the
+// flag or the process environment, never from the .env file, which a cloned repository could
+// ship. The second result reports whether the flag was given.
+func selectionSetting(cmd *cobra.Command, name string) (string, bool) {
+	if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+		return flag.Value.String(), true
+	}
+	return os.Getenv("GHMV_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))), false
+}
+
+// Returns the config file to read: the --config flag, or config.yaml, config.yml, or config.toml
+// in <user config dir>/gh-migrate-variables. An empty path means there is no config file.
+func configFilePath(cmd *cobra.Command) (string, error) {
+	if path, _ := selectionSetting(cmd, "config"); path != "" {
+		return path, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", nil
+	}
+	for _, name := range []string{"config.yaml", "config.yml", "config.toml"} {
+		path := filepath.Join(configDir, "gh-migrate-variables", name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// Reads the config file, returning nil if there is none
+func loadConfigFile(cmd *cobra.Command) (*ConfigFile, string, error) {
+	path, err := configFilePath(cmd)
+	if err != nil || path == "" {
+		return nil, "", err
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, path, fmt.Errorf("cannot read config file %s: %w", path, err)
+	}
+	var config ConfigFile
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, path, fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+	return &config, path, nil
+}
+
+// Applies the profiles selected with --source-profile and --target-profile to the flags of the
+// command being run. Flags given on the command line take precedence over profile values.
+func applyProfiles(cmd *cobra.Command) error {
+	sourceName, sourceExplicit := selectionSetting(cmd, "source-profile")
+	targetName, targetExplicit := selectionSetting(cmd, "target-profile")
+	if sourceName == "" && targetName == "" {
+		return nil
+	}
+
+	config, path, err := loadConfigFile(cmd)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return fmt.Errorf("a profile was requested but there is no config file, pass --config or create one in the user config directory")
+	}
+
+	// The proxy settings are shared by both endpoints, so when a command connects to both, the
+	// target profile is applied first and its settings win
+	for _, selected := range []struct {
+		prefix, name string
+		explicit     bool
+	}{{"target", targetName, targetExplicit}, {"source", sourceName, sourceExplicit}} {
+		// A profile only applies to commands that connect to its endpoint
+		if selected.name == "" || cmd.Flags().Lookup(selected.prefix+"-token") == nil {
+			continue
+		}
+		// viper lowercases keys, so profile names are case-insensitive
+		profile, ok := config.Profiles[strings.ToLower(selected.name)]
+		if !ok {
+			return fmt.Errorf("profile %q not found in %s (available: %s)", selected.name, path, strings.Join(profileNames(config), ", "))
+		}
+		if err := applyProfile(cmd, selected.prefix, profile, selected.explicit); err != nil {
+			return fmt.Errorf("profile %q: %w", selected.name, err)
+		}
+	}
+	return nil
+}
+
+// Sets the unchanged flags of a command from a profile. Connection and TLS values go to the
+// <prefix>-hostname, <prefix>-organization, <prefix>-token, and <prefix>-<tls setting> flags.
+// Explicit is set when the profile was selected with its flag.
+func applyProfile(cmd *cobra.Command, prefix string, profile Profile, explicit bool) error {
+	token := profile.Token
+	if profile.TokenEnv != "" {
+		token = os.Getenv(profile.TokenEnv)
+		if token == "" {
+			return fmt.Errorf("environment variable %s is not set", profile.TokenEnv)
+		}
+	}
+
+	values := map[string]string{
+		prefix + "-hostname":             profile.Hostname,
+		prefix + "-organization":         profile.Organization,
+		prefix + "-token":                token,
+		prefix + "-ca-bundle":            profile.TLS.CABundle,
+		prefix + "-client-cert":          profile.TLS.ClientCert,
+		prefix + "-client-key":           profile.TLS.ClientKey,
+		prefix + "-min-tls-version":      profile.TLS.MinVersion,
+		prefix + "-insecure-skip-verify": profile.TLS.InsecureSkipVerify,
+		"http-proxy":                     profile.Proxy.HTTP,
+		"https-proxy":                    profile.Proxy.HTTPS,
+		"no-proxy":                       profile.Proxy.NoProxy,
+		"proxy-username":                 profile.Proxy.Username,
+		"proxy-password":                 profile.Proxy.Password,
+		prefix + "-timeout":              profile.Timeout,
+		prefix + "-max-idle-conns":       profile.MaxIdleConns,
+		prefix + "-retry-max":            profile.Retry.Max,
+		prefix + "-retry-delay":          profile.Retry.Delay,
+		prefix + "-retry-max-delay":      profile.Retry.MaxDelay,
+		prefix + "-retry-budget":         profile.Retry.Budget,
+		prefix + "-backoff":              profile.Retry.Backoff,
+	}
+	for name, value := range profile.Defaults {
+		values[name] = value
+	}
+
+	for name, value := range values {
+		flag := cmd.Flags().Lookup(name)
+		// Settings for flags the command does not have are ignored, so one profile serves every command
+		if value == "" || flag == nil || flag.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+		}
+		if name == prefix+"-token" {
+			profileTokens[name] = explicit
+		}
+	}
+	return nil
+}
+
+// Returns the profile names in the config file, sorted
+func profileNames(config *ConfigFile) []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
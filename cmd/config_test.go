@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const commandToken = "cmd:echo ghp_profile"
+
+// Creates a command with the flags that select and receive a profile, and a config file whose
+// profile holds a cmd: token
+func newProfileCommand(t *testing.T) (*cobra.Command, string) {
+	t.Helper()
+	profileTokens = make(map[string]bool)
+	t.Cleanup(viper.Reset)
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := "profiles:\n  ci:\n    token: \"" + commandToken + "\"\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{Use: "export"}
+	cmd.Flags().String("config", "", "")
+	cmd.Flags().String("source-profile", "", "")
+	cmd.Flags().String("source-token", "", "")
+	cmd.Flags().String("target-profile", "", "")
+	return cmd, configPath
+}
+
+// Changes into a directory for the rest of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+func TestDotEnvCannotSelectProfile(t *testing.T) {
+	cmd, configPath := newProfileCommand(t)
+	dir := t.TempDir()
+	dotEnv := "GHMV_CONFIG=" + configPath + "\nGHMV_SOURCE_PROFILE=ci\n"
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(dotEnv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+	initConfig()
+
+	if err := applyProfiles(cmd); err != nil {
+		t.Fatalf("applyProfiles: %v", err)
+	}
+	if token, _ := cmd.Flags().GetString("source-token"); token != "" {
+		t.Fatalf("profile selected by .env set the token to %q", token)
+	}
+}
+
+func TestCommandTokenSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, cmd *cobra.Command, configPath string)
+		wantErr bool
+	}{
+		{
+			name: "token flag",
+			setup: func(t *testing.T, cmd *cobra.Command, configPath string) {
+				cmd.Flags().Set("source-token", commandToken)
+			},
+		},
+		{
+			name: "profile selected with flags",
+			setup: func(t *testing.T, cmd *cobra.Command, configPath string) {
+				cmd.Flags().Set("config", configPath)
+				cmd.Flags().Set("source-profile", "ci")
+			},
+		},
+		{
+			name: "profile selected with config flag and environment",
+			setup: func(t *testing.T, cmd *cobra.Command, configPath string) {
+				cmd.Flags().Set("config", configPath)
+				t.Setenv("GHMV_SOURCE_PROFILE", "ci")
+			},
+			wantErr: true,
+		},
+		{
+			name: "profile selected through environment",
+			setup: func(t *testing.T, cmd *cobra.Command, configPath string) {
+				t.Setenv("GHMV_CONFIG", configPath)
+				t.Setenv("GHMV_SOURCE_PROFILE", "ci")
+			},
+			wantErr: true,
+		},
+		{
+			name:    "token from environment",
+			setup:   func(t *testing.T, cmd *cobra.Command, configPath string) {},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, configPath := newProfileCommand(t)
+			tt.setup(t, cmd, configPath)
+			if err := applyProfiles(cmd); err != nil {
+				t.Fatalf("applyProfiles: %v", err)
+			}
+
+			token, _ := cmd.Flags().GetString("source-token")
+			if token == "" {
+				// Stands in for GHMV_SOURCE_TOKEN, which GetFlagOrViperValue reads through viper
+				token = commandToken
+			}
+			err := checkTokenSource(cmd, "source-token", token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkTokenSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/doctor"
+	"github.com/spf13/cobra"
+)
+
+var DoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks the scopes and permissions of the source and target tokens",
+	Long:  "Checks the scopes and permissions of the source and target tokens: token validity, OAuth scopes, organization membership and role, and read and write access on a sample repository. The same checks run before every export, sync, and apply.",
+	Run: func(cmd *cobra.Command, args []string) {
+		BindFlags(cmd, "skip-write-test")
+		values := GetFlagOrViperValue(cmd, map[string]bool{
+			"source-hostname":     false,
+			"source-organization": false,
+			"source-token":        false,
+			"source-sample-repo":  false,
+			"target-hostname":     false,
+			"target-organization": false,
+			"target-token":        false,
+			"target-sample-repo":  false,
+		})
+		if values["source-token"] != "" {
+			ShowConnectionStatus("export")
+		}
+		if values["target-token"] != "" {
+			ShowConnectionStatus("sync")
+		}
+		if err := doctor.RunDoctor(); err != nil {
+			fmt.Printf("failed to run doctor: %v\n", err)
+			logging.Exit(1)
+		}
+		return
+	},
+}
+
+func init() {
+	// Add flags to the DoctorCmd. The connection flags are read through GetFlagOrViperValue, which
+	// also falls back to the GHMV_ environment variables the export and sync commands use.
+	DoctorCmd.Flags().String("source-hostname", "", "Source GitHub Enterprise Server hostname (optional) Ex. github.example.com")
+	DoctorCmd.Flags().StringSlice("source-organization", nil, "Source organization to check, repeat or comma-separate for multiple")
+	DoctorCmd.Flags().String("source-token", "", "Source GitHub token or token source: gh, file:<path>, fd:<n>, or cmd:<command>")
+	DoctorCmd.Flags().String("source-sample-repo", "", "Source repository to test read access on (default the first unarchived repository)")
+	DoctorCmd.Flags().String("target-hostname", "", "Target GitHub Enterprise Server hostname (optional) Ex. github.example.com")
+	DoctorCmd.Flags().StringSlice("target-organization", nil, "Target organization to check, repeat or comma-separate for multiple")
+	DoctorCmd.Flags().String("target-token", "", "Target GitHub token or token source: gh, file:<path>, fd:<n>, or cmd:<command>")
+	DoctorCmd.Flags().String("target-sample-repo", "", "Target repository to test read and write access on (default the first unarchived repository)")
+	DoctorCmd.Flags().Bool("skip-write-test", false, "Do not write a temporary variable to test write access on the target sample repository")
+}
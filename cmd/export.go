@@ -14,11 +14,18 @@ var ExportCmd = &cobra.Command{
 	Short: "Exports organization and repository variables to CSV",
 	Long:  "Exports organization and repository variables to CSV",
 	Run: func(cmd *cobra.Command, args []string) {
+		BindFlags(cmd, "interactive")
 		GetFlagOrViperValue(cmd, map[string]bool{
 			"source-hostname":     false,
-			"source-organization": true,
+			"source-organization": false,
 			"source-token":        true,
 			"search-depth":        false,
+			"organizations-file":  false,
+			"enterprise":          false,
+			"output-file":         false,
+			"secret-stores":       false,
+			"secret-scan-policy":  false,
+			"secret-scan-ignore":  false,
 		})
 		ShowConnectionStatus("export")
 		if err := export.ExportVariables(); err != nil {
@@ -31,11 +38,28 @@ var ExportCmd = &cobra.Command{
 func init() {
 	// Add flags to the ExportCmd
 	ExportCmd.Flags().StringP("source-hostname", "n", "", "GitHub Enterprise Server hostname (optional) Ex. github.example.com")
-	ExportCmd.Flags().StringP("source-organization", "o", "", "Organization to export (required)")
-	ExportCmd.Flags().StringP("source-token", "t", "", "GitHub token (required)")
+	ExportCmd.Flags().StringSliceP("source-organization", "o", nil, "Organization to export, repeat or comma-separate for multiple (required unless --organizations-file or --enterprise is set)")
+	ExportCmd.Flags().StringP("source-token", "t", "", "GitHub token or token source: gh, file:<path>, fd:<n>, or cmd:<command> (required)")
+	ExportCmd.Flags().String("organizations-file", "", "File with one organization per line to export")
+	ExportCmd.Flags().StringP("enterprise", "e", "", "Enterprise slug, exports every organization in the enterprise")
+	ExportCmd.Flags().String("output-file", "", "Combined output CSV file (default <organization>_variables.csv)")
+	ExportCmd.Flags().Bool("per-org-files", false, "Write one CSV file per organization instead of a combined file")
+	ExportCmd.Flags().Bool("include-secrets", false, "Also export the names, visibility, and selected repositories of secrets to a separate <organization>_secrets.csv")
+	ExportCmd.Flags().Bool("effective-values", false, "Also write <organization>_effective_values.csv with the value every repository and environment sees for each variable, flagging conflicts across scopes")
+	ExportCmd.Flags().BoolP("interactive", "i", false, "Prompt for missing connection values and select the organizations and repositories to export")
+	ExportCmd.Flags().String("secret-scan-policy", "", "What to do with variables whose values look like credentials: off, warn (default), suggest (also show the command that creates a secret instead), or block (leave them out of the CSV and fail)")
+	ExportCmd.Flags().StringSlice("secret-scan-ignore", nil, "Variable names to exclude from the credential scan, repeat or comma-separate for multiple")
+	ExportCmd.Flags().StringSlice("secret-stores", []string{"actions", "dependabot", "codespaces"}, "Secret stores to include with --include-secrets: actions, dependabot, codespaces")
 
 	// Bind flags to viper
 	viper.BindPFlag("GHMV_SOURCE_HOSTNAME", ExportCmd.Flags().Lookup("source-hostname"))
 	viper.BindPFlag("GHMV_SOURCE_ORGANIZATION", ExportCmd.Flags().Lookup("source-organization"))
 	viper.BindPFlag("GHMV_SOURCE_TOKEN", ExportCmd.Flags().Lookup("source-token"))
+	viper.BindPFlag("GHMV_ORGANIZATIONS_FILE", ExportCmd.Flags().Lookup("organizations-file"))
+	viper.BindPFlag("GHMV_ENTERPRISE", ExportCmd.Flags().Lookup("enterprise"))
+	viper.BindPFlag("GHMV_OUTPUT_FILE", ExportCmd.Flags().Lookup("output-file"))
+	viper.BindPFlag("GHMV_PER_ORG_FILES", ExportCmd.Flags().Lookup("per-org-files"))
+	viper.BindPFlag("GHMV_INCLUDE_SECRETS", ExportCmd.Flags().Lookup("include-secrets"))
+	viper.BindPFlag("GHMV_EFFECTIVE_VALUES", ExportCmd.Flags().Lookup("effective-values"))
+	viper.BindPFlag("GHMV_SECRET_STORES", ExportCmd.Flags().Lookup("secret-stores"))
 }
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/rollback"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var RollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Reverses a previous sync using its journal file",
+	Long:  "Reverses a previous sync using its journal file. Variables created by the sync are deleted, updated variables are restored to their previous values, and pruned variables are recreated.",
+	Run: func(cmd *cobra.Command, args []string) {
+		BindFlags(cmd, "dry-run")
+		GetFlagOrViperValue(cmd, map[string]bool{
+			"journal":         true,
+			"target-hostname": false,
+			"target-token":    true,
+		})
+		ShowConnectionStatus("rollback")
+		if err := rollback.RollbackSync(); err != nil {
+			fmt.Printf("failed to roll back variables: %v\n", err)
+			logging.Exit(1)
+		}
+		return
+	},
+}
+
+func init() {
+	// Add flags to the RollbackCmd
+	RollbackCmd.Flags().StringP("journal", "j", "", "Journal file written by a previous sync (required)")
+	RollbackCmd.Flags().StringP("target-hostname", "n", "", "GitHub Enterprise Server hostname URL (optional) Ex. https://github.example.com")
+	RollbackCmd.Flags().StringP("target-token", "t", "", "GitHub token or token source: gh, file:<path>, fd:<n>, or cmd:<command> (required)")
+	RollbackCmd.Flags().Bool("dry-run", false, "Show what would be rolled back without making changes")
+
+	// Bind flags to viper
+	viper.BindPFlag("GHMV_JOURNAL", RollbackCmd.Flags().Lookup("journal"))
+}
@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -11,6 +15,25 @@ var rootCmd = &cobra.Command{
 	Use:   "migrate-variables",
 	Short: "gh cli extension to assist in the migration of variables between GitHub enterprises",
 	Long:  "gh cli extension to assist in the migration of variables between GitHub enterprises",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := applyProfiles(cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := output.Setup(viper.GetString("OUTPUT_MODE")); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		output.SetVerbose(viper.GetBool("VERBOSE"))
+		if err := logging.Setup(viper.GetString("LOG_LEVEL"), viper.GetString("LOG_FORMAT"), viper.GetString("LOG_FILE")); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		logging.Started(cmd.CommandPath())
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		logging.Finish(0)
+	},
 }
 
 func Execute() error {
@@ -21,22 +44,72 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	// Add root command flags
+	rootCmd.PersistentFlags().String("config", "", "Config file with connection profiles (default <user config dir>/gh-migrate-variables/config.yaml)")
+	rootCmd.PersistentFlags().String("source-profile", "", "Config file profile for the source instance")
+	rootCmd.PersistentFlags().String("target-profile", "", "Config file profile for the target instance")
 	rootCmd.PersistentFlags().String("http-proxy", "", "HTTP proxy (can also use HTTP_PROXY env var)")
 	rootCmd.PersistentFlags().String("https-proxy", "", "HTTPS proxy (can also use HTTPS_PROXY env var)")
-	rootCmd.PersistentFlags().String("no-proxy", "", "No proxy list (can also use NO_PROXY env var)")
+	rootCmd.PersistentFlags().String("no-proxy", "", "Hosts, domains, IPs, CIDR ranges, and host:port entries that bypass the proxy, * for all (can also use NO_PROXY env var)")
+	rootCmd.PersistentFlags().String("proxy-username", "", "Proxy username, when the proxy URL has no credentials (can also use PROXY_USERNAME env var)")
+	rootCmd.PersistentFlags().String("proxy-password", "", "Proxy password, when the proxy URL has no credentials (can also use PROXY_PASSWORD env var)")
+	for _, endpoint := range []string{"source", "target"} {
+		rootCmd.PersistentFlags().String(endpoint+"-ca-bundle", "", "PEM file of CA certificates to trust for the "+endpoint+" instance, in addition to the system roots")
+		rootCmd.PersistentFlags().String(endpoint+"-client-cert", "", "Client certificate PEM file for the "+endpoint+" instance")
+		rootCmd.PersistentFlags().String(endpoint+"-client-key", "", "Client certificate key PEM file for the "+endpoint+" instance")
+		rootCmd.PersistentFlags().String(endpoint+"-min-tls-version", "1.2", "Minimum TLS version for the "+endpoint+" instance: 1.0, 1.1, 1.2, or 1.3")
+		rootCmd.PersistentFlags().Bool(endpoint+"-insecure-skip-verify", false, "Do not verify the TLS certificate of the "+endpoint+" instance (INSECURE, for testing only)")
+		rootCmd.PersistentFlags().String(endpoint+"-timeout", "30s", "Timeout of a single API call to the "+endpoint+" instance")
+		rootCmd.PersistentFlags().String(endpoint+"-retry-budget", "5m", "Total time an API call to the "+endpoint+" instance may take across all retries")
+		rootCmd.PersistentFlags().Int(endpoint+"-retry-max", 0, "Maximum attempts per API call to the "+endpoint+" instance (default --retry-max)")
+		rootCmd.PersistentFlags().String(endpoint+"-retry-delay", "", "First delay between retries for the "+endpoint+" instance, doubled on every retry (default --retry-delay)")
+		rootCmd.PersistentFlags().String(endpoint+"-retry-max-delay", "30s", "Longest delay between retries for the "+endpoint+" instance")
+		rootCmd.PersistentFlags().String(endpoint+"-backoff", "exponential", "Backoff between retries for the "+endpoint+" instance: exponential, or jitter for a random delay up to the exponential one")
+		rootCmd.PersistentFlags().Int(endpoint+"-max-idle-conns", 10, "Idle connections kept open to the "+endpoint+" instance")
+	}
+	rootCmd.PersistentFlags().String("output-mode", "auto", "Console output: pretty, plain for CI logs, json for NDJSON events, or auto to pick pretty on a terminal and plain otherwise")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Print a line for every repository and variable in addition to the progress bars")
+	rootCmd.PersistentFlags().String("log-level", "", "Log level: debug, info, warn, or error, without --log-file the log goes to stderr (default info)")
+	rootCmd.PersistentFlags().String("log-format", "text", "Log format: text or json")
+	rootCmd.PersistentFlags().String("log-file", "", "Append structured logs to this file")
 	rootCmd.PersistentFlags().Int("retry-max", 3, "Maximum retry attempts")
 	rootCmd.PersistentFlags().String("retry-delay", "1s", "Delay between retries")
+	rootCmd.PersistentFlags().Bool("skip-preflight", false, "Skip the GitHub Enterprise Server version and capability check")
+	rootCmd.PersistentFlags().String("repo-listing", "auto", "How to list organization repositories: auto (GraphQL for large organizations), rest, or graphql")
+	rootCmd.PersistentFlags().String("repo-cache-ttl", "0", "Reuse repository catalogs cached on disk for this long, e.g. 1h (0 disables the cache)")
+	rootCmd.PersistentFlags().String("repo-cache-dir", "", "Directory for cached repository catalogs (default <user cache dir>/gh-migrate-variables/repositories)")
 
 	// Bind flags to viper
 	viper.BindPFlag("HTTP_PROXY", rootCmd.PersistentFlags().Lookup("http-proxy"))
 	viper.BindPFlag("HTTPS_PROXY", rootCmd.PersistentFlags().Lookup("https-proxy"))
 	viper.BindPFlag("NO_PROXY", rootCmd.PersistentFlags().Lookup("no-proxy"))
+	viper.BindPFlag("PROXY_USERNAME", rootCmd.PersistentFlags().Lookup("proxy-username"))
+	viper.BindPFlag("PROXY_PASSWORD", rootCmd.PersistentFlags().Lookup("proxy-password"))
+	for _, endpoint := range []string{"source", "target"} {
+		for _, name := range []string{"ca-bundle", "client-cert", "client-key", "min-tls-version", "insecure-skip-verify",
+			"timeout", "retry-budget", "retry-max", "retry-delay", "retry-max-delay", "backoff", "max-idle-conns"} {
+			flag := endpoint + "-" + name
+			viper.BindPFlag("GHMV_"+strings.ToUpper(strings.ReplaceAll(flag, "-", "_")), rootCmd.PersistentFlags().Lookup(flag))
+		}
+	}
+	viper.BindPFlag("OUTPUT_MODE", rootCmd.PersistentFlags().Lookup("output-mode"))
+	viper.BindPFlag("VERBOSE", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("LOG_LEVEL", rootCmd.PersistentFlags().Lookup("log-level"))
+	viper.BindPFlag("LOG_FORMAT", rootCmd.PersistentFlags().Lookup("log-format"))
+	viper.BindPFlag("LOG_FILE", rootCmd.PersistentFlags().Lookup("log-file"))
 	viper.BindPFlag("RETRY_MAX", rootCmd.PersistentFlags().Lookup("retry-max"))
 	viper.BindPFlag("RETRY_DELAY", rootCmd.PersistentFlags().Lookup("retry-delay"))
+	viper.BindPFlag("SKIP_PREFLIGHT", rootCmd.PersistentFlags().Lookup("skip-preflight"))
+	viper.BindPFlag("REPO_LISTING", rootCmd.PersistentFlags().Lookup("repo-listing"))
+	viper.BindPFlag("REPO_CACHE_TTL", rootCmd.PersistentFlags().Lookup("repo-cache-ttl"))
+	viper.BindPFlag("REPO_CACHE_DIR", rootCmd.PersistentFlags().Lookup("repo-cache-dir"))
 
 	// Add subcommands
 	rootCmd.AddCommand(ExportCmd)
 	rootCmd.AddCommand(SyncCmd)
+	rootCmd.AddCommand(RollbackCmd)
+	rootCmd.AddCommand(ApplyCmd)
+	rootCmd.AddCommand(DoctorCmd)
+	rootCmd.AddCommand(AuditCmd)
 
 	// hide -h, --help from global/proxy flags
 	rootCmd.Flags().BoolP("help", "h", false, "")
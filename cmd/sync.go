@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
 	"github.com/mona-actions/gh-migrate-variables/pkg/sync"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -13,15 +14,36 @@ var SyncCmd = &cobra.Command{
 	Short: "Sync organization and repository variables from CSV",
 	Long:  "Sync organization and repository variables from CSV",
 	Run: func(cmd *cobra.Command, args []string) {
+		BindFlags(cmd, "dry-run", "prune", "yes", "pending", "interactive")
+		pending := viper.GetBool("GHMV_PENDING")
 		GetFlagOrViperValue(cmd, map[string]bool{
-			"file":                true,
+			"file":                !pending,
 			"target-hostname":     false,
-			"target-organization": true,
+			"target-organization": false,
 			"target-token":        true,
+			"mapping-file":        false,
+			"collision-policy":    false,
+			"journal-file":        false,
+			"secrets-file":        false,
+			"secret-values":       false,
+			"archived-policy":     false,
+			"pending-file":        false,
+			"wait-timeout":        false,
+			"poll-interval":       false,
+			"secret-scan-policy":  false,
+			"secret-scan-ignore":  false,
 		})
 		ShowConnectionStatus("sync")
+		if pending {
+			if err := sync.SyncPending(); err != nil {
+				fmt.Printf("failed to sync pending variables: %v\n", err)
+				logging.Exit(1)
+			}
+			return
+		}
 		if err := sync.SyncVariables(); err != nil {
-			fmt.Printf("failed to export variables: %v\n", err)
+			fmt.Printf("failed to sync variables: %v\n", err)
+			logging.Exit(1)
 		}
 		return
 	},
@@ -31,12 +53,37 @@ func init() {
 	// Add flags to the SyncCmd
 	SyncCmd.Flags().StringP("file", "f", "", "Input CSV file with variables to sync")
 	SyncCmd.Flags().StringP("target-hostname", "n", "", "GitHub Enterprise Server hostname URL (optional) Ex. https://github.example.com")
-	SyncCmd.Flags().StringP("target-organization", "o", "", "Organization to export (required)")
-	SyncCmd.Flags().StringP("target-token", "t", "", "GitHub token (required)")
+	SyncCmd.Flags().StringP("target-organization", "o", "", "Target organization to sync variables to (required unless --mapping-file is set)")
+	SyncCmd.Flags().StringP("target-token", "t", "", "GitHub token or token source: gh, file:<path>, fd:<n>, or cmd:<command> (required)")
+	SyncCmd.Flags().StringP("mapping-file", "m", "", "CSV file routing source organizations and repositories to target organizations")
+	SyncCmd.Flags().String("journal-file", "", "File to record changes in for rollback (default <target-organization>_journal_<timestamp>.jsonl)")
+	SyncCmd.Flags().String("secrets-file", "", "Secrets inventory CSV written by export --include-secrets, secrets are created in the target")
+	SyncCmd.Flags().String("secret-values", "", "CSV file with Name,Scope,Environment,Value columns holding the secret values (falls back to GHMV_SECRET_<NAME>)")
+	SyncCmd.Flags().String("pending-file", "", "File queuing variables for target repositories that do not exist yet (default <target-organization>_pending.csv)")
+	SyncCmd.Flags().Bool("pending", false, "Replay the variables queued in the pending file instead of syncing an input file")
+	SyncCmd.Flags().String("wait-timeout", "0s", "With --pending, how long to poll for queued repositories to appear, e.g. 2h")
+	SyncCmd.Flags().String("poll-interval", "30s", "With --pending, how often to check whether queued repositories exist")
+	SyncCmd.Flags().Bool("prune", false, "Delete target variables that are not in the input file, limited to the scopes present in the file")
+	SyncCmd.Flags().Bool("dry-run", false, "Show what would be created, updated, and pruned without making changes")
+	SyncCmd.Flags().BoolP("yes", "y", false, "Delete pruned variables without asking for confirmation")
+	SyncCmd.Flags().BoolP("interactive", "i", false, "Prompt for missing connection values, select the organizations, repositories, and variables to sync, and confirm the plan")
+	SyncCmd.Flags().String("archived-policy", "skip", "How to handle archived target repositories: skip, unarchive (temporarily unarchive and re-archive), or fail")
+	SyncCmd.Flags().String("secret-scan-policy", "", "What to do with variables whose values look like credentials: off, warn (default), suggest (also show the command that creates a secret instead), or block (skip them and fail)")
+	SyncCmd.Flags().StringSlice("secret-scan-ignore", nil, "Variable names to exclude from the credential scan, repeat or comma-separate for multiple")
+	SyncCmd.Flags().String("collision-policy", "fail", "How to handle org variables with the same name from different source organizations: prefix, first-wins, fail, or demote")
 
 	// Bind flags to viper
 	viper.BindPFlag("GHMV_TARGET_HOSTNAME", SyncCmd.Flags().Lookup("target-hostname"))
 	viper.BindPFlag("GHMV_TARGET_ORGANIZATION", SyncCmd.Flags().Lookup("target-organization"))
 	viper.BindPFlag("GHMV_TARGET_TOKEN", SyncCmd.Flags().Lookup("target-token"))
 	viper.BindPFlag("GHMV_CSV_FILE", SyncCmd.Flags().Lookup("file"))
+	viper.BindPFlag("GHMV_MAPPING_FILE", SyncCmd.Flags().Lookup("mapping-file"))
+	viper.BindPFlag("GHMV_COLLISION_POLICY", SyncCmd.Flags().Lookup("collision-policy"))
+	viper.BindPFlag("GHMV_JOURNAL_FILE", SyncCmd.Flags().Lookup("journal-file"))
+	viper.BindPFlag("GHMV_ARCHIVED_POLICY", SyncCmd.Flags().Lookup("archived-policy"))
+	viper.BindPFlag("GHMV_PENDING_FILE", SyncCmd.Flags().Lookup("pending-file"))
+	viper.BindPFlag("GHMV_WAIT_TIMEOUT", SyncCmd.Flags().Lookup("wait-timeout"))
+	viper.BindPFlag("GHMV_POLL_INTERVAL", SyncCmd.Flags().Lookup("poll-interval"))
+	viper.BindPFlag("GHMV_SECRETS_FILE", SyncCmd.Flags().Lookup("secrets-file"))
+	viper.BindPFlag("GHMV_SECRET_VALUES", SyncCmd.Flags().Lookup("secret-values"))
 }
@@ -2,43 +2,41 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/google/go-github/v66/github"
 	"github.com/pterm/pterm"
-	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
 )
 
-type ProxyConfig struct {
-	HTTPProxy  string
-	HTTPSProxy string
-	NoProxy    string
-}
-
 type GitHubClientConfig struct {
-	Token    string
-	Hostname string
+	TokenSource oauth2.TokenSource
+	Hostname    string
+	// Role is the source or target endpoint whose connection settings the client uses
+	Role string
 }
 
 const (
 	defaultVariableVisibility = "private"
 	EntityTypeOrg             = "organization"
 	EntityTypeRepository      = "repository"
+	EntityTypeEnvironment     = "environment"
 )
 
-// Helper function to create a consistent API context with a timeout
-func createAPITimeoutContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 30*time.Second)
+// Helper function to create a consistent API context with the per-call timeout of the client's endpoint
+func createAPITimeoutContext(client *github.Client) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), clientSettings(client).CallTimeout)
 }
 
-// Helper function to create a longer-lived context for retry operations
-func createLongLivedContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 5*time.Minute)
+// Helper function to create a longer-lived context bounded by the retry budget of the client's endpoint
+func createLongLivedContext(client *github.Client) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), clientSettings(client).RetryBudget)
 }
 
 // Helper function to handle optional hostname parameter
@@ -49,64 +47,46 @@ func extractHostname(hostname ...string) string {
 	return ""
 }
 
-// Creates a proxy function based on the provided ProxyConfig
-func buildProxyFunction(proxyConfig *ProxyConfig) func(*http.Request) (*url.URL, error) {
-	return func(req *http.Request) (*url.URL, error) {
-		// Check if the request host should bypass the proxy based on NoProxy settings
-		if proxyConfig != nil && proxyConfig.NoProxy != "" {
-			noProxyURLs := strings.Split(proxyConfig.NoProxy, ",")
-			reqHost := req.URL.Host
-			for _, noProxy := range noProxyURLs {
-				if strings.TrimSpace(noProxy) == reqHost {
-					return nil, nil
-				}
-			}
-		}
-		// Determine the appropriate proxy URL based on the scheme (http or https)
-		if proxyConfig != nil {
-			if req.URL.Scheme == "https" && proxyConfig.HTTPSProxy != "" {
-				return url.Parse(proxyConfig.HTTPSProxy)
-			}
-			if req.URL.Scheme == "http" && proxyConfig.HTTPProxy != "" {
-				return url.Parse(proxyConfig.HTTPProxy)
-			}
-		}
-		return nil, nil
-	}
-}
-
-// Retrieves proxy configuration from environment variables
-func loadProxyConfigFromEnv() *ProxyConfig {
-	return &ProxyConfig{
-		HTTPProxy:  viper.GetString("HTTP_PROXY"),
-		HTTPSProxy: viper.GetString("HTTPS_PROXY"),
-		NoProxy:    viper.GetString("NO_PROXY"),
-	}
-}
-
 // Creates a new GitHub client with optional proxy and enterprise hostname support
 func initializeGitHubClient(config GitHubClientConfig) (*github.Client, error) {
-	if config.Token == "" {
+	if config.TokenSource == nil {
 		return nil, fmt.Errorf("GitHub token is required")
 	}
 
-	// Create an OAuth2 HTTP client
+	// Clients are reused per token and endpoint so that their idle connections are too
+	key, cacheable := clientKey{}, false
+	if source, ok := config.TokenSource.(tokenSource); ok {
+		key, cacheable = clientKey{source: source, hostname: config.Hostname}, true
+		clientsMu.Lock()
+		client, ok := clients[key]
+		clientsMu.Unlock()
+		if ok {
+			return client, nil
+		}
+	}
+
+	// Create an OAuth2 HTTP client. Tokens from providers are resolved on the first request.
 	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.Token})
+	ts := config.TokenSource
+	endpoint := endpointKey{role: config.Role, hostname: config.Hostname}
+	settings := endpointSettings(endpoint)
 
 	// Set up proxy configuration if available
 	proxyConfig := loadProxyConfigFromEnv()
 	transport := &http.Transport{
 		Proxy:                 buildProxyFunction(proxyConfig),
-		ResponseHeaderTimeout: 10 * time.Second,
+		TLSClientConfig:       endpointTLSConfig(endpoint),
+		ResponseHeaderTimeout: settings.CallTimeout,
 		TLSHandshakeTimeout:   10 * time.Second,
 		IdleConnTimeout:       10 * time.Second,
+		MaxIdleConns:          settings.MaxIdleConns,
+		MaxIdleConnsPerHost:   settings.MaxIdleConns,
 	}
 
 	// Create an HTTP client with the configured transport
 	tc := oauth2.NewClient(ctx, ts)
 	tc.Transport = &oauth2.Transport{
-		Base:   transport,
+		Base:   &loggingTransport{base: transport},
 		Source: ts,
 	}
 
@@ -125,22 +105,19 @@ func initializeGitHubClient(config GitHubClientConfig) (*github.Client, error) {
 		}
 	}
 
+	clientsMu.Lock()
+	clientEndpoints[client] = endpoint
+	if cacheable {
+		clients[key] = client
+	}
+	clientsMu.Unlock()
 	return client, nil
 }
 
-// Retries the given operation with a context, using an exponential backoff strategy
-func retryWithExponentialBackoff(ctx context.Context, operation func() error) error {
-	// Retrieve the maximum number of retries from configuration, defaulting to 3 if not set
-	maxRetries := viper.GetInt("RETRY_MAX")
-	if maxRetries <= 0 {
-		maxRetries = 3
-	}
-
-	// Retrieve the retry delay from configuration, defaulting to 1 second if not set
-	retryDelay, err := time.ParseDuration(viper.GetString("RETRY_DELAY"))
-	if err != nil {
-		retryDelay = time.Second
-	}
+// Retries the given operation with a context, using an exponential backoff strategy that is
+// capped at the endpoint's maximum delay and optionally randomized with full jitter
+func retryWithExponentialBackoff(ctx context.Context, settings EndpointConfig, operation func() error) error {
+	maxRetries := settings.RetryMax
 
 	var lastErr error
 	// Attempt the operation, retrying with exponential backoff if it fails
@@ -152,8 +129,9 @@ func retryWithExponentialBackoff(ctx context.Context, operation func() error) er
 			lastErr = err
 			// If the operation fails and more retries are allowed, wait before retrying
 			if attempt < maxRetries {
-				waitTime := retryDelay * time.Duration(1<<uint(attempt-1))
+				waitTime := backoffDelay(settings, attempt)
 				pterm.Warning.Printf("Attempt %d failed, retrying in %v: %v\n", attempt, waitTime, lastErr)
+				slog.Warn("retrying API call", "attempt", attempt, "wait", waitTime.String(), "error", lastErr)
 
 				// select waits for either context cancellation or the backoff timer to expire
 				select {
@@ -172,12 +150,28 @@ func retryWithExponentialBackoff(ctx context.Context, operation func() error) er
 	return fmt.Errorf("operation failed after %d attempts: %w", maxRetries, lastErr)
 }
 
-// Wrapper function to retry an operation with a default context
-func retryWithDefaultContext(operation func() error) error {
+// Returns the delay before the retry that follows a failed attempt: the first delay doubled for
+// every earlier attempt, capped, and with jitter a random duration up to that delay
+func backoffDelay(settings EndpointConfig, attempt int) time.Duration {
+	delay := settings.RetryDelay
+	for i := 1; i < attempt && delay < settings.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > settings.RetryMaxDelay {
+		delay = settings.RetryMaxDelay
+	}
+	if settings.Backoff == BackoffJitter && delay > 0 {
+		delay = rand.N(delay + 1)
+	}
+	return delay
+}
+
+// Wrapper function to retry an operation with the retry settings and budget of the client's endpoint
+func retryWithDefaultContext(client *github.Client, operation func() error) error {
 	// Create a longer-lived context for retries
-	ctx, cancel := createLongLivedContext()
+	ctx, cancel := createLongLivedContext(client)
 	// Retry the operation using the created context
-	err := retryWithExponentialBackoff(ctx, operation)
+	err := retryWithExponentialBackoff(ctx, clientSettings(client), operation)
 	cancel()
 	return err
 }
@@ -205,56 +199,84 @@ func parseGitHubVariable(variable *github.ActionsVariable, scope string) map[str
 	return parsedVar
 }
 
-// Retrieves variables from a GitHub organization or repository
-func fetchGitHubVariables(entityType, org, repo, token string, hostname ...string) ([]map[string]string, error) {
+// Builds the scope reported for a variable: "organization", the repository name, or repository/environment
+func variableScope(entityType, repo, env string) string {
+	switch entityType {
+	case EntityTypeRepository:
+		return repo
+	case EntityTypeEnvironment:
+		return repo + "/" + env
+	}
+	return entityType
+}
+
+// Retrieves variables from a GitHub organization, repository, or environment
+func fetchGitHubVariables(entityType, org, repo, env, token string, hostname ...string) ([]map[string]string, error) {
 	// Validate that the organization name is provided
 	if org == "" {
 		return nil, fmt.Errorf("organization name is required")
 	}
 	// Validate that the repository name is provided for repository-level variables
-	if entityType == EntityTypeRepository && repo == "" {
+	if entityType != EntityTypeOrg && repo == "" {
 		return nil, fmt.Errorf("repository name is required")
 	}
+	// Validate that the environment name is provided for environment-level variables
+	if entityType == EntityTypeEnvironment && env == "" {
+		return nil, fmt.Errorf("environment name is required")
+	}
 
 	// Initialize a new GitHub client
-	client, err := initializeGitHubClient(GitHubClientConfig{Token: token, Hostname: extractHostname(hostname...)})
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
 	}
 
-	var variables *github.ActionsVariables
-	// Retry the variable retrieval operation
-	err = retryWithDefaultContext(func() error {
-		ctx, cancel := createAPITimeoutContext()
-		defer cancel()
-		var apiErr error
+	// Request the maximum page size and follow pagination so no variables are missed
+	opts := &github.ListOptions{PerPage: 100}
+	var allVariables []*github.ActionsVariable
+	for {
+		var variables *github.ActionsVariables
+		var resp *github.Response
+		// Retry the variable retrieval operation
+		err = retryWithDefaultContext(client, func() error {
+			ctx, cancel := createAPITimeoutContext(client)
+			defer cancel()
+			var apiErr error
+
+			// Retrieve variables based on entity type (organization, repository, or environment)
+			switch entityType {
+			case EntityTypeOrg:
+				variables, resp, apiErr = client.Actions.ListOrgVariables(ctx, org, opts)
+			case EntityTypeEnvironment:
+				variables, resp, apiErr = client.Actions.ListEnvVariables(ctx, org, repo, env, opts)
+			default:
+				variables, resp, apiErr = client.Actions.ListRepoVariables(ctx, org, repo, opts)
+			}
+			return apiErr
+		})
 
-		// Retrieve variables based on entity type (organization or repository)
-		if entityType == EntityTypeOrg {
-			variables, _, apiErr = client.Actions.ListOrgVariables(ctx, org, nil)
-		} else {
-			variables, _, apiErr = client.Actions.ListRepoVariables(ctx, org, repo, nil)
+		// Handle any errors from the variable retrieval process
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s variables: %w", entityType, err)
 		}
-		return apiErr
-	})
 
-	// Handle any errors from the variable retrieval process
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch %s variables: %w", entityType, err)
-	}
+		if variables == nil {
+			return nil, fmt.Errorf("no variables data returned for %s %s", entityType, org)
+		}
+		allVariables = append(allVariables, variables.Variables...)
 
-	if variables == nil {
-		return nil, fmt.Errorf("no variables data returned for %s %s", entityType, org)
+		// If there are no more pages, stop paginating
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
 	}
 
 	// Parse and collect the variables into a slice of maps
 	var parsedVariables []map[string]string
-	scope := entityType
-	if entityType == EntityTypeRepository {
-		scope = repo
-	}
+	scope := variableScope(entityType, repo, env)
 
-	for _, variable := range variables.Variables {
+	for _, variable := range allVariables {
 		parsedVar := parseGitHubVariable(variable, scope)
 		if parsedVar != nil {
 			parsedVariables = append(parsedVariables, parsedVar)
@@ -267,28 +289,38 @@ func fetchGitHubVariables(entityType, org, repo, token string, hostname ...strin
 // Retrieves organization-level variables from GitHub
 func FetchOrgVariables(org, token string, hostname ...string) ([]map[string]string, error) {
 	// Calls fetchGitHubVariables for organization-level variables
-	return fetchGitHubVariables(EntityTypeOrg, org, "", token, hostname...)
+	return fetchGitHubVariables(EntityTypeOrg, org, "", "", token, hostname...)
 }
 
 // Retrieves repository-level variables from GitHub
 func FetchRepoVariables(org, repo, token string, hostname ...string) ([]map[string]string, error) {
 	// Calls fetchGitHubVariables for repository-level variables
-	return fetchGitHubVariables(EntityTypeRepository, org, repo, token, hostname...)
+	return fetchGitHubVariables(EntityTypeRepository, org, repo, "", token, hostname...)
+}
+
+// Retrieves environment-level variables from GitHub
+func FetchEnvVariables(org, repo, env, token string, hostname ...string) ([]map[string]string, error) {
+	// Calls fetchGitHubVariables for environment-level variables
+	return fetchGitHubVariables(EntityTypeEnvironment, org, repo, env, token, hostname...)
 }
 
 // Creates a variable in a GitHub organization or repository
-func addGitHubVariable(entityType, org, repo, name, value, visibility, token string, hostname ...string) error {
+func addGitHubVariable(entityType, org, repo, env, name, value, visibility, token string, hostname ...string) error {
 	// Validate that the organization name and variable name are provided
 	if org == "" || name == "" {
 		return fmt.Errorf("organization name and variable name are required")
 	}
 	// Validate that the repository name is provided for repository-level variables
-	if entityType == EntityTypeRepository && repo == "" {
+	if entityType != EntityTypeOrg && repo == "" {
 		return fmt.Errorf("repository name is required")
 	}
+	// Validate that the environment name is provided for environment-level variables
+	if entityType == EntityTypeEnvironment && env == "" {
+		return fmt.Errorf("environment name is required")
+	}
 
-	// Check if the repository exists if creating a repo variable
-	if entityType == EntityTypeRepository {
+	// Check if the repository exists if creating a repo or environment variable
+	if entityType != EntityTypeOrg {
 		exists, err := doesRepositoryExist(org, repo, token, hostname...)
 		if err != nil {
 			return fmt.Errorf("failed to check repository existence: %w", err)
@@ -299,7 +331,7 @@ func addGitHubVariable(entityType, org, repo, name, value, visibility, token str
 	}
 
 	// Initialize a new GitHub client
-	client, err := initializeGitHubClient(GitHubClientConfig{Token: token, Hostname: extractHostname(hostname...)})
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
 	if err != nil {
 		return fmt.Errorf("failed to initialize GitHub client: %w", err)
 	}
@@ -315,18 +347,26 @@ func addGitHubVariable(entityType, org, repo, name, value, visibility, token str
 		Value:      value,
 		Visibility: github.String(visibility),
 	}
+	// Environment variables have no visibility of their own
+	if entityType == EntityTypeEnvironment {
+		variable.Visibility = nil
+	}
 
 	// Retry the variable creation operation
-	err = retryWithDefaultContext(func() error {
-		ctx, cancel := createAPITimeoutContext()
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
 		defer cancel()
 
-		// Create the variable based on the entity type (organization or repository)
-		if entityType == EntityTypeOrg {
+		// Create the variable based on the entity type (organization, repository, or environment)
+		switch entityType {
+		case EntityTypeOrg:
 			_, err := client.Actions.CreateOrgVariable(ctx, org, variable)
 			return err
+		case EntityTypeEnvironment:
+			_, err := client.Actions.CreateEnvVariable(ctx, org, repo, env, variable)
+			return err
 		}
-		_, err = client.Actions.CreateRepoVariable(ctx, org, repo, variable)
+		_, err := client.Actions.CreateRepoVariable(ctx, org, repo, variable)
 		return err
 	})
 
@@ -341,25 +381,410 @@ func addGitHubVariable(entityType, org, repo, name, value, visibility, token str
 // Creates an organization-level variable in GitHub
 func AddOrgVariable(org, name, value, visibility, token string, hostname ...string) error {
 	// Calls addGitHubVariable for an organization-level variable
-	return addGitHubVariable(EntityTypeOrg, org, "", name, value, visibility, token, hostname...)
+	return addGitHubVariable(EntityTypeOrg, org, "", "", name, value, visibility, token, hostname...)
 }
 
 // Creates a repository-level variable in GitHub
 func AddRepoVariable(org, repo, name, value, visibility, token string, hostname ...string) error {
 	// Calls addGitHubVariable for a repository-level variable
-	return addGitHubVariable(EntityTypeRepository, org, repo, name, value, visibility, token, hostname...)
+	return addGitHubVariable(EntityTypeRepository, org, repo, "", name, value, visibility, token, hostname...)
+}
+
+// Creates an environment-level variable in GitHub
+func AddEnvVariable(org, repo, env, name, value, token string, hostname ...string) error {
+	// Calls addGitHubVariable for an environment-level variable
+	return addGitHubVariable(EntityTypeEnvironment, org, repo, env, name, value, "", token, hostname...)
+}
+
+// Reports whether an error returned by the GitHub API is a 404 Not Found
+func IsNotFound(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// Reports whether an error returned by the GitHub API is a 403 Forbidden, such as a missing permission
+func IsForbidden(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusForbidden
+}
+
+// Retrieves a single variable from a GitHub organization or repository, returning nil if it does not exist
+func getGitHubVariable(entityType, org, repo, env, name, token string, hostname ...string) (map[string]string, error) {
+	// Validate that the organization name and variable name are provided
+	if org == "" || name == "" {
+		return nil, fmt.Errorf("organization name and variable name are required")
+	}
+	// Validate that the repository name is provided for repository-level variables
+	if entityType != EntityTypeOrg && repo == "" {
+		return nil, fmt.Errorf("repository name is required")
+	}
+	// Validate that the environment name is provided for environment-level variables
+	if entityType == EntityTypeEnvironment && env == "" {
+		return nil, fmt.Errorf("environment name is required")
+	}
+
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var variable *github.ActionsVariable
+	// Retry the variable retrieval operation, a missing variable is not retried
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		var apiErr error
+
+		switch entityType {
+		case EntityTypeOrg:
+			variable, _, apiErr = client.Actions.GetOrgVariable(ctx, org, name)
+		case EntityTypeEnvironment:
+			variable, _, apiErr = client.Actions.GetEnvVariable(ctx, org, repo, env, name)
+		default:
+			variable, _, apiErr = client.Actions.GetRepoVariable(ctx, org, repo, name)
+		}
+		if IsNotFound(apiErr) {
+			variable = nil
+			return nil
+		}
+		return apiErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s variable %s: %w", entityType, name, err)
+	}
+
+	scope := variableScope(entityType, repo, env)
+	return parseGitHubVariable(variable, scope), nil
+}
+
+// Retrieves an organization-level variable from GitHub, returning nil if it does not exist
+func GetOrgVariable(org, name, token string, hostname ...string) (map[string]string, error) {
+	// Calls getGitHubVariable for an organization-level variable
+	return getGitHubVariable(EntityTypeOrg, org, "", "", name, token, hostname...)
+}
+
+// Retrieves a repository-level variable from GitHub, returning nil if it does not exist
+func GetRepoVariable(org, repo, name, token string, hostname ...string) (map[string]string, error) {
+	// Calls getGitHubVariable for a repository-level variable
+	return getGitHubVariable(EntityTypeRepository, org, repo, "", name, token, hostname...)
+}
+
+// Retrieves an environment-level variable from GitHub, returning nil if it does not exist
+func GetEnvVariable(org, repo, env, name, token string, hostname ...string) (map[string]string, error) {
+	// Calls getGitHubVariable for an environment-level variable
+	return getGitHubVariable(EntityTypeEnvironment, org, repo, env, name, token, hostname...)
+}
+
+// Updates an existing variable in a GitHub organization or repository
+func updateGitHubVariable(entityType, org, repo, env, name, value, visibility, token string, hostname ...string) error {
+	// Validate that the organization name and variable name are provided
+	if org == "" || name == "" {
+		return fmt.Errorf("organization name and variable name are required")
+	}
+	// Validate that the repository name is provided for repository-level variables
+	if entityType != EntityTypeOrg && repo == "" {
+		return fmt.Errorf("repository name is required")
+	}
+	// Validate that the environment name is provided for environment-level variables
+	if entityType == EntityTypeEnvironment && env == "" {
+		return fmt.Errorf("environment name is required")
+	}
+
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	// Set default visibility if not provided
+	if visibility == "" {
+		visibility = defaultVariableVisibility
+	}
+
+	variable := &github.ActionsVariable{
+		Name:       name,
+		Value:      value,
+		Visibility: github.String(visibility),
+	}
+	// Environment variables have no visibility of their own
+	if entityType == EntityTypeEnvironment {
+		variable.Visibility = nil
+	}
+
+	// Retry the variable update operation
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+
+		switch entityType {
+		case EntityTypeOrg:
+			_, err := client.Actions.UpdateOrgVariable(ctx, org, variable)
+			return err
+		case EntityTypeEnvironment:
+			_, err := client.Actions.UpdateEnvVariable(ctx, org, repo, env, variable)
+			return err
+		}
+		_, err := client.Actions.UpdateRepoVariable(ctx, org, repo, variable)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update %s variable %s: %w", entityType, name, err)
+	}
+
+	return nil
+}
+
+// Updates an organization-level variable in GitHub
+func UpdateOrgVariable(org, name, value, visibility, token string, hostname ...string) error {
+	// Calls updateGitHubVariable for an organization-level variable
+	return updateGitHubVariable(EntityTypeOrg, org, "", "", name, value, visibility, token, hostname...)
+}
+
+// Updates a repository-level variable in GitHub
+func UpdateRepoVariable(org, repo, name, value, visibility, token string, hostname ...string) error {
+	// Calls updateGitHubVariable for a repository-level variable
+	return updateGitHubVariable(EntityTypeRepository, org, repo, "", name, value, visibility, token, hostname...)
+}
+
+// Updates an environment-level variable in GitHub
+func UpdateEnvVariable(org, repo, env, name, value, token string, hostname ...string) error {
+	// Calls updateGitHubVariable for an environment-level variable
+	return updateGitHubVariable(EntityTypeEnvironment, org, repo, env, name, value, "", token, hostname...)
+}
+
+// Deletes a variable from a GitHub organization or repository
+func deleteGitHubVariable(entityType, org, repo, env, name, token string, hostname ...string) error {
+	// Validate that the organization name and variable name are provided
+	if org == "" || name == "" {
+		return fmt.Errorf("organization name and variable name are required")
+	}
+	// Validate that the repository name is provided for repository-level variables
+	if entityType != EntityTypeOrg && repo == "" {
+		return fmt.Errorf("repository name is required")
+	}
+	// Validate that the environment name is provided for environment-level variables
+	if entityType == EntityTypeEnvironment && env == "" {
+		return fmt.Errorf("environment name is required")
+	}
+
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	// Retry the variable deletion operation
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+
+		switch entityType {
+		case EntityTypeOrg:
+			_, err := client.Actions.DeleteOrgVariable(ctx, org, name)
+			return err
+		case EntityTypeEnvironment:
+			_, err := client.Actions.DeleteEnvVariable(ctx, org, repo, env, name)
+			return err
+		}
+		_, err := client.Actions.DeleteRepoVariable(ctx, org, repo, name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s variable %s: %w", entityType, name, err)
+	}
+
+	return nil
+}
+
+// Deletes an organization-level variable from GitHub
+func DeleteOrgVariable(org, name, token string, hostname ...string) error {
+	// Calls deleteGitHubVariable for an organization-level variable
+	return deleteGitHubVariable(EntityTypeOrg, org, "", "", name, token, hostname...)
+}
+
+// Deletes a repository-level variable from GitHub
+func DeleteRepoVariable(org, repo, name, token string, hostname ...string) error {
+	// Calls deleteGitHubVariable for a repository-level variable
+	return deleteGitHubVariable(EntityTypeRepository, org, repo, "", name, token, hostname...)
+}
+
+// Deletes an environment-level variable from GitHub
+func DeleteEnvVariable(org, repo, env, name, token string, hostname ...string) error {
+	// Calls deleteGitHubVariable for an environment-level variable
+	return deleteGitHubVariable(EntityTypeEnvironment, org, repo, env, name, token, hostname...)
+}
+
+// Creates a deployment environment in a repository, leaving an existing one unchanged
+func EnsureEnvironment(org, repo, env, token string, hostname ...string) error {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	// Retry the environment lookup and creation
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+
+		_, _, err := client.Repositories.GetEnvironment(ctx, org, repo, env)
+		if err == nil || !IsNotFound(err) {
+			return err
+		}
+		_, _, err = client.Repositories.CreateUpdateEnvironment(ctx, org, repo, env, &github.CreateUpdateEnvironment{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create environment %s in %s/%s: %w", env, org, repo, err)
+	}
+	return nil
+}
+
+// Retrieves the names of the repositories selected for an organization variable
+func FetchSelectedReposForOrgVariable(org, name, token string, hostname ...string) ([]string, error) {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	opts := &github.ListOptions{PerPage: 100}
+	var repos []string
+	for {
+		var selected *github.SelectedReposList
+		var resp *github.Response
+		// Retry the selected repository retrieval operation
+		err = retryWithDefaultContext(client, func() error {
+			ctx, cancel := createAPITimeoutContext(client)
+			defer cancel()
+			var apiErr error
+			selected, resp, apiErr = client.Actions.ListSelectedReposForOrgVariable(ctx, org, name, opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch selected repositories for variable %s: %w", name, err)
+		}
+
+		if selected != nil {
+			for _, repo := range selected.Repositories {
+				if repo != nil && repo.Name != nil {
+					repos = append(repos, *repo.Name)
+				}
+			}
+		}
+
+		// If there are no more pages, stop paginating
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return repos, nil
+}
+
+// Replaces the repositories selected for an organization variable with the named repositories
+func SetSelectedReposForOrgVariable(org, name string, repos []string, token string, hostname ...string) error {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	// The API takes repository IDs, so resolve each repository name first
+	ids := make(github.SelectedRepoIDs, 0, len(repos))
+	for _, repo := range repos {
+		id, err := FetchRepositoryID(org, repo, token, hostname...)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	// Retry the selected repository update operation
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		_, err := client.Actions.SetSelectedReposForOrgVariable(ctx, org, name, ids)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set selected repositories for variable %s: %w", name, err)
+	}
+	return nil
+}
+
+// Retrieves the ID of a repository in a given organization
+func FetchRepositoryID(org, repo, token string, hostname ...string) (int64, error) {
+	// Use the repository catalog when it has already been built
+	if catalog := cachedRepoCatalog(org, token, hostname...); catalog != nil {
+		if repository, ok := catalog.Lookup(repo); ok {
+			return repository.ID, nil
+		}
+	}
+
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var repository *github.Repository
+	// Retry the repository retrieval operation
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		var apiErr error
+		repository, _, apiErr = client.Repositories.Get(ctx, org, repo)
+		return apiErr
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch repository %s/%s: %w", org, repo, err)
+	}
+	return repository.GetID(), nil
+}
+
+// Archives or unarchives a repository in a given organization
+func SetRepositoryArchived(org, repo string, archived bool, token string, hostname ...string) error {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	// Retry the repository update operation
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		_, _, err := client.Repositories.Edit(ctx, org, repo, &github.Repository{Archived: github.Bool(archived)})
+		return err
+	})
+	if err != nil {
+		action := "unarchive"
+		if archived {
+			action = "archive"
+		}
+		return fmt.Errorf("failed to %s repository %s/%s: %w", action, org, repo, err)
+	}
+	return nil
 }
 
 // Checks if a repository exists in a given organization
 func doesRepositoryExist(org, repo, token string, hostname ...string) (bool, error) {
+	// Check locally when the repository catalog has already been built
+	if catalog := cachedRepoCatalog(org, token, hostname...); catalog != nil {
+		return catalog.Exists(repo), nil
+	}
+
 	// Initialize a new GitHub client
-	client, err := initializeGitHubClient(GitHubClientConfig{Token: token, Hostname: extractHostname(hostname...)})
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
 	if err != nil {
 		return false, fmt.Errorf("failed to initialize GitHub client: %w", err)
 	}
 
 	// Create a context with a timeout
-	ctx, cancel := createAPITimeoutContext()
+	ctx, cancel := createAPITimeoutContext(client)
 	defer cancel()
 
 	// Attempt to retrieve the repository
@@ -371,13 +796,52 @@ func doesRepositoryExist(org, repo, token string, hostname ...string) (bool, err
 	return resp.StatusCode == 200, nil
 }
 
+// Retrieves a single repository from the API, bypassing the repository catalog and its cache, so
+// that a repository created or archived since the catalog was built is seen. Returns nil if the
+// repository does not exist.
+func FetchRepository(org, repo, token string, hostname ...string) (*Repository, error) {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var found *github.Repository
+	// Retry the repository retrieval operation, a missing repository is not retried
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		var apiErr error
+		found, _, apiErr = client.Repositories.Get(ctx, org, repo)
+		if IsNotFound(apiErr) {
+			found = nil
+			return nil
+		}
+		return apiErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repository %s/%s: %w", org, repo, err)
+	}
+	if found == nil {
+		return nil, nil
+	}
+	repository := repositoryFromGitHub(found)
+	return &repository, nil
+}
+
+// Checks if a repository exists in a given organization
+func RepositoryExists(org, repo, token string, hostname ...string) (bool, error) {
+	// Calls doesRepositoryExist for an exported existence check
+	return doesRepositoryExist(org, repo, token, hostname...)
+}
+
 // Lists paginated GitHub resources, such as repositories
-func listPaginatedRepositories(fetch func(opts *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error)) ([]string, error) {
+func listPaginatedRepositories(fetch func(opts *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error)) ([]Repository, error) {
 	// Set up pagination options, requesting 100 items per page
 	opts := &github.RepositoryListByOrgOptions{
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
-	var allResources []string
+	var allResources []Repository
 
 	// Iterate through pages of results
 	for {
@@ -389,10 +853,10 @@ func listPaginatedRepositories(fetch func(opts *github.RepositoryListByOrgOption
 			return nil, fmt.Errorf("no data returned")
 		}
 
-		// Collect repository names from the current page
+		// Collect repository metadata from the current page
 		for _, repo := range repos {
 			if repo != nil && repo.Name != nil {
-				allResources = append(allResources, *repo.Name)
+				allResources = append(allResources, repositoryFromGitHub(repo))
 			}
 		}
 
@@ -407,18 +871,62 @@ func listPaginatedRepositories(fetch func(opts *github.RepositoryListByOrgOption
 	return allResources, nil
 }
 
-// Retrieves a list of repositories for a given organization
-func FetchAllRepositories(org, token string, hostname ...string) ([]string, error) {
+// Retrieves the metadata of every repository in an organization through the REST API
+func fetchRepositoriesREST(org, token string, hostname ...string) ([]Repository, error) {
 	// Initialize a new GitHub client
-	client, err := initializeGitHubClient(GitHubClientConfig{Token: token, Hostname: extractHostname(hostname...)})
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
 	}
 
 	// Use listPaginatedRepositories to fetch all repositories in the organization
 	return listPaginatedRepositories(func(opts *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
-		ctx, cancel := createAPITimeoutContext()
+		ctx, cancel := createAPITimeoutContext(client)
 		defer cancel()
 		return client.Repositories.ListByOrg(ctx, org, opts)
 	})
 }
+
+// Retrieves a list of repositories for a given organization
+func FetchAllRepositories(org, token string, hostname ...string) ([]string, error) {
+	catalog, err := FetchRepoCatalog(org, token, hostname...)
+	if err != nil {
+		return nil, err
+	}
+	return catalog.Names(), nil
+}
+
+// Retrieves the login of every organization the token's user belongs to
+func FetchUserOrganizations(token string, hostname ...string) ([]string, error) {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var organizations []string
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var orgs []*github.Organization
+		var resp *github.Response
+		// Retry the organization listing operation
+		err = retryWithDefaultContext(client, func() error {
+			ctx, cancel := createAPITimeoutContext(client)
+			defer cancel()
+			var apiErr error
+			orgs, resp, apiErr = client.Organizations.List(ctx, "", opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organizations: %w", err)
+		}
+		for _, org := range orgs {
+			organizations = append(organizations, org.GetLogin())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return organizations, nil
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// API features whose availability depends on the GitHub Enterprise Server version
+const (
+	FeatureActionsVariables     = "Actions variables"
+	FeatureEnvironmentVariables = "Environment variables"
+	FeatureActionsSecrets       = "Actions secrets"
+	FeatureDependabotSecrets    = "Dependabot secrets"
+	FeatureCodespacesSecrets    = "Codespaces secrets"
+	FeatureGraphQLRepoListing   = "GraphQL repository listing"
+)
+
+// Minimum GHES version for each feature. An empty version means the feature is not available on GHES.
+var featureMinimumVersions = map[string]string{
+	FeatureActionsVariables:     "3.8",
+	FeatureEnvironmentVariables: "3.8",
+	FeatureActionsSecrets:       "3.0",
+	FeatureDependabotSecrets:    "3.4",
+	FeatureCodespacesSecrets:    "",
+	FeatureGraphQLRepoListing:   "3.0",
+}
+
+// Capabilities describes the API features a GitHub instance supports
+type Capabilities struct {
+	Hostname string
+	// Version is the GHES version, empty for GitHub.com
+	Version string
+}
+
+// Capabilities already detected in this run, keyed by hostname
+var (
+	capabilitiesMu    sync.Mutex
+	capabilitiesCache = make(map[string]*Capabilities)
+)
+
+// Reports whether the instance is GitHub Enterprise Server
+func (c *Capabilities) IsEnterpriseServer() bool {
+	return c.Version != ""
+}
+
+// Reports whether the instance supports a feature. Unknown versions are assumed to support everything.
+func (c *Capabilities) Supports(feature string) bool {
+	if !c.IsEnterpriseServer() {
+		return true
+	}
+	minimum, ok := featureMinimumVersions[feature]
+	if !ok {
+		return true
+	}
+	if minimum == "" {
+		return false
+	}
+	return compareVersions(c.Version, minimum) >= 0
+}
+
+// Returns the feature that covers a secret store
+func SecretStoreFeature(store string) string {
+	switch store {
+	case SecretStoreDependabot:
+		return FeatureDependabotSecrets
+	case SecretStoreCodespaces:
+		return FeatureCodespacesSecrets
+	}
+	return FeatureActionsSecrets
+}
+
+// Returns the minimum GHES version a feature needs, or an empty string if GHES does not offer it
+func FeatureMinimumVersion(feature string) string {
+	return featureMinimumVersions[feature]
+}
+
+// Detects the version of a GitHub instance from the X-GitHub-Enterprise-Version header or the
+// installed_version field of /meta. GitHub.com reports neither.
+func DetectCapabilities(token string, hostname ...string) (*Capabilities, error) {
+	host := extractHostname(hostname...)
+
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+	if capabilities, ok := capabilitiesCache[host]; ok {
+		return capabilities, nil
+	}
+
+	capabilities := &Capabilities{Hostname: host}
+	if host != "" {
+		// Initialize a new GitHub client
+		client, err := initializeGitHubClient(newClientConfig(token, host))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+		}
+
+		var meta struct {
+			InstalledVersion string `json:"installed_version"`
+		}
+		var header string
+		// Retry the metadata retrieval operation
+		err = retryWithDefaultContext(client, func() error {
+			ctx, cancel := createAPITimeoutContext(client)
+			defer cancel()
+
+			req, err := client.NewRequest("GET", "meta", nil)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Do(ctx, req, &meta)
+			if resp != nil {
+				header = resp.Header.Get("X-GitHub-Enterprise-Version")
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch instance metadata: %w", err)
+		}
+
+		capabilities.Version = header
+		if capabilities.Version == "" {
+			capabilities.Version = meta.InstalledVersion
+		}
+	}
+
+	capabilitiesCache[host] = capabilities
+	return capabilities, nil
+}
+
+// Returns the capabilities of an instance if they were already detected in this run
+func cachedCapabilities(hostname string) *Capabilities {
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+	return capabilitiesCache[hostname]
+}
+
+// Compares two dotted version strings, returning -1, 0, or 1
+func compareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+	}
+	return 0
+}
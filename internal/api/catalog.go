@@ -0,0 +1,310 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v66/github"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// Repository holds the repository metadata the tool needs, fetched once per organization
+type Repository struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	Archived      bool   `json:"archived"`
+	Visibility    string `json:"visibility"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// RepoCatalog is the list of repositories in an organization, indexed by name
+type RepoCatalog struct {
+	Organization string       `json:"organization"`
+	Hostname     string       `json:"hostname"`
+	FetchedAt    time.Time    `json:"fetched_at"`
+	Repositories []Repository `json:"repositories"`
+
+	byName map[string]int
+}
+
+// Catalogs already built in this run, keyed by hostname, organization, and token fingerprint
+var (
+	catalogMu    sync.Mutex
+	catalogCache = make(map[string]*RepoCatalog)
+)
+
+// Repository listing methods
+const (
+	RepoListingAuto    = "auto"
+	RepoListingREST    = "rest"
+	RepoListingGraphQL = "graphql"
+)
+
+// Organizations with at least this many repositories are listed through GraphQL in auto mode
+const graphQLRepoThreshold = 1000
+
+var unsafeCacheFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Catalogs are cached per token, because tokens with different access see different repositories
+func catalogKey(org, hostname, fingerprint string) string {
+	return hostname + "/" + strings.ToLower(org) + "/" + fingerprint
+}
+
+// Returns a short hash identifying the token a token spec resolves to. The token itself is never
+// stored; specs that cannot be resolved are hashed as they are.
+func tokenFingerprint(token, hostname string) string {
+	if resolved, err := ResolveToken(token, hostname); err == nil {
+		token = resolved
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// Builds the name index. GitHub repository names are case-insensitive.
+func (c *RepoCatalog) index() {
+	c.byName = make(map[string]int, len(c.Repositories))
+	for i, repo := range c.Repositories {
+		c.byName[strings.ToLower(repo.Name)] = i
+	}
+}
+
+// Looks up a repository by name
+func (c *RepoCatalog) Lookup(name string) (Repository, bool) {
+	i, ok := c.byName[strings.ToLower(name)]
+	if !ok {
+		return Repository{}, false
+	}
+	return c.Repositories[i], true
+}
+
+// Reports whether a repository exists in the organization
+func (c *RepoCatalog) Exists(name string) bool {
+	_, ok := c.Lookup(name)
+	return ok
+}
+
+// Returns the names of every repository in the catalog
+func (c *RepoCatalog) Names() []string {
+	names := make([]string, 0, len(c.Repositories))
+	for _, repo := range c.Repositories {
+		names = append(names, repo.Name)
+	}
+	return names
+}
+
+// NewRepoCatalog builds a catalog from repositories that were looked up individually. It is not
+// added to the cache.
+func NewRepoCatalog(org, hostname string, repos []Repository) *RepoCatalog {
+	catalog := &RepoCatalog{
+		Organization: org,
+		Hostname:     hostname,
+		FetchedAt:    time.Now().UTC(),
+		Repositories: repos,
+	}
+	catalog.index()
+	return catalog
+}
+
+// Converts a REST repository into catalog metadata
+func repositoryFromGitHub(repo *github.Repository) Repository {
+	visibility := repo.GetVisibility()
+	if visibility == "" {
+		// Older GHES versions only report whether a repository is private
+		visibility = "public"
+		if repo.GetPrivate() {
+			visibility = "private"
+		}
+	}
+	return Repository{
+		ID:            repo.GetID(),
+		Name:          repo.GetName(),
+		Archived:      repo.GetArchived(),
+		Visibility:    visibility,
+		DefaultBranch: repo.GetDefaultBranch(),
+	}
+}
+
+// Retrieves the repository catalog for an organization. The catalog is built once per run and,
+// when a cache TTL is configured, reused from disk across runs until it expires.
+func FetchRepoCatalog(org, token string, hostname ...string) (*RepoCatalog, error) {
+	host := extractHostname(hostname...)
+	fingerprint := tokenFingerprint(token, host)
+	key := catalogKey(org, host, fingerprint)
+
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	if catalog, ok := catalogCache[key]; ok {
+		return catalog, nil
+	}
+
+	ttl := repoCatalogTTL()
+	if ttl > 0 {
+		if catalog, err := loadCachedCatalog(org, host, fingerprint, ttl); err == nil && catalog != nil {
+			catalogCache[key] = catalog
+			return catalog, nil
+		}
+	}
+
+	repos, err := fetchRepositories(org, token, host)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := NewRepoCatalog(org, host, repos)
+	catalogCache[key] = catalog
+
+	if ttl > 0 {
+		if err := saveCachedCatalog(catalog, fingerprint); err != nil {
+			pterm.Warning.Printf("Failed to cache repository catalog for %s: %v\n", org, err)
+		}
+	}
+	return catalog, nil
+}
+
+// Lists the repositories of an organization through REST or GraphQL, depending on the configured
+// listing method. GraphQL is used for large organizations in auto mode, and REST is used whenever
+// GraphQL fails, such as on older GHES versions that lack the fields the query needs.
+func fetchRepositories(org, token, hostname string) ([]Repository, error) {
+	method := strings.ToLower(viper.GetString("REPO_LISTING"))
+	// Instances known to lack the fields the GraphQL query needs are always listed through REST
+	if capabilities := cachedCapabilities(hostname); capabilities != nil && !capabilities.Supports(FeatureGraphQLRepoListing) {
+		return fetchRepositoriesREST(org, token, hostname)
+	}
+	switch method {
+	case "", RepoListingAuto:
+		if !isLargeOrganization(org, token, hostname) {
+			return fetchRepositoriesREST(org, token, hostname)
+		}
+	case RepoListingREST:
+		return fetchRepositoriesREST(org, token, hostname)
+	case RepoListingGraphQL:
+	default:
+		return nil, fmt.Errorf("unknown repository listing method %q (expected auto, rest, or graphql)", method)
+	}
+
+	repos, err := fetchRepositoriesGraphQL(org, token, hostname)
+	if err != nil {
+		pterm.Warning.Printf("GraphQL repository listing failed for %s, falling back to REST: %v\n", org, err)
+		return fetchRepositoriesREST(org, token, hostname)
+	}
+	return repos, nil
+}
+
+// Reports whether an organization owns enough repositories to be listed through GraphQL.
+// Organizations whose size cannot be determined are treated as small.
+func isLargeOrganization(org, token, hostname string) bool {
+	client, err := initializeGitHubClient(newClientConfig(token, hostname))
+	if err != nil {
+		return false
+	}
+
+	var organization *github.Organization
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		var apiErr error
+		organization, _, apiErr = client.Organizations.Get(ctx, org)
+		return apiErr
+	})
+	if err != nil || organization == nil {
+		return false
+	}
+	// Private repository counts are only visible to organization owners
+	return organization.GetPublicRepos()+int(organization.GetTotalPrivateRepos()) >= graphQLRepoThreshold
+}
+
+// Returns the catalog for an organization if it was already built in this run with the same token
+func cachedRepoCatalog(org, token string, hostname ...string) *RepoCatalog {
+	host := extractHostname(hostname...)
+	fingerprint := tokenFingerprint(token, host)
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	return catalogCache[catalogKey(org, host, fingerprint)]
+}
+
+// Reads the catalog cache TTL from configuration. Zero disables the disk cache.
+func repoCatalogTTL() time.Duration {
+	ttl, err := time.ParseDuration(viper.GetString("REPO_CACHE_TTL"))
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// Returns the directory catalogs are cached in, defaulting to the user cache directory
+func repoCatalogDir() (string, error) {
+	if dir := viper.GetString("REPO_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "gh-migrate-variables", "repositories"), nil
+}
+
+// Returns the cache file for an organization's catalog as seen by a token
+func repoCatalogPath(org, hostname, fingerprint string) (string, error) {
+	dir, err := repoCatalogDir()
+	if err != nil {
+		return "", err
+	}
+	host := hostname
+	if host == "" {
+		host = "github.com"
+	}
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	name := unsafeCacheFileChars.ReplaceAllString(host+"_"+strings.ToLower(org), "_") + "_" + fingerprint + ".json"
+	return filepath.Join(dir, name), nil
+}
+
+// Loads a cached catalog from disk, returning nil if there is none or it has expired
+func loadCachedCatalog(org, hostname, fingerprint string, ttl time.Duration) (*RepoCatalog, error) {
+	path, err := repoCatalogPath(org, hostname, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var catalog RepoCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("cannot parse cached catalog %s: %w", path, err)
+	}
+	if time.Since(catalog.FetchedAt) > ttl {
+		return nil, nil
+	}
+	catalog.index()
+	return &catalog, nil
+}
+
+// Writes a catalog to the disk cache
+func saveCachedCatalog(catalog *RepoCatalog, fingerprint string) error {
+	path, err := repoCatalogPath(catalog.Organization, catalog.Hostname, fingerprint)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("cannot create cache directory: %w", err)
+	}
+	data, err := json.Marshal(catalog)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
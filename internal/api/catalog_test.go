@@ -0,0 +1,55 @@
+package api
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestRepoCatalogPath(t *testing.T) {
+	dir := t.TempDir()
+	viper.Set("REPO_CACHE_DIR", dir)
+	t.Cleanup(viper.Reset)
+
+	const token = "ghp_secret"
+	tests := []struct {
+		name     string
+		org      string
+		hostname string
+		token    string
+		want     string
+	}{
+		{name: "github.com", org: "Mona", token: token, want: "github.com_mona_" + tokenFingerprint(token, "") + ".json"},
+		{name: "server hostname", org: "mona", hostname: "https://ghes.example.com", token: token, want: "ghes.example.com_mona_" + tokenFingerprint(token, "https://ghes.example.com") + ".json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := repoCatalogPath(tt.org, tt.hostname, tokenFingerprint(tt.token, tt.hostname))
+			if err != nil {
+				t.Fatalf("repoCatalogPath: %v", err)
+			}
+			if path != filepath.Join(dir, tt.want) {
+				t.Fatalf("got %s, want %s", path, filepath.Join(dir, tt.want))
+			}
+			if strings.Contains(path, tt.token) {
+				t.Fatalf("cache path %s contains the token", path)
+			}
+		})
+	}
+}
+
+func TestCatalogKeyDependsOnToken(t *testing.T) {
+	first := catalogKey("mona", "", tokenFingerprint("ghp_first", ""))
+	second := catalogKey("mona", "", tokenFingerprint("ghp_second", ""))
+	if first == second {
+		t.Fatalf("tokens share the catalog key %s", first)
+	}
+	if strings.Contains(first, "ghp_first") {
+		t.Fatalf("catalog key %s contains the token", first)
+	}
+	if again := catalogKey("Mona", "", tokenFingerprint("ghp_first", "")); again != first {
+		t.Fatalf("same token and organization gave keys %s and %s", first, again)
+	}
+}
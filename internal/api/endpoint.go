@@ -0,0 +1,239 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v66/github"
+	"github.com/spf13/viper"
+)
+
+// Backoff strategies between retries
+const (
+	BackoffExponential = "exponential"
+	BackoffJitter      = "jitter"
+)
+
+// Connection defaults for endpoints that were not configured
+const (
+	defaultCallTimeout   = 30 * time.Second
+	defaultRetryBudget   = 5 * time.Minute
+	defaultRetryMax      = 3
+	defaultRetryDelay    = time.Second
+	defaultRetryMaxDelay = 30 * time.Second
+	defaultMaxIdleConns  = 10
+)
+
+// EndpointConfig holds the connection settings of one GitHub instance, so that the source and
+// target can be configured separately
+type EndpointConfig struct {
+	// CABundle is a PEM file of certificates trusted in addition to the system roots
+	CABundle   string
+	ClientCert string
+	ClientKey  string
+	// MinTLSVersion is 1.0, 1.1, 1.2, or 1.3, defaulting to 1.2
+	MinTLSVersion      string
+	InsecureSkipVerify bool
+
+	// CallTimeout bounds a single API call, including waiting for the response headers
+	CallTimeout time.Duration
+	// RetryBudget bounds the total time spent on a call and all of its retries
+	RetryBudget time.Duration
+	RetryMax    int
+	// RetryDelay is the first backoff delay, doubled on every retry up to RetryMaxDelay
+	RetryDelay    time.Duration
+	RetryMaxDelay time.Duration
+	// Backoff is BackoffExponential, or BackoffJitter to wait a random part of each delay
+	Backoff      string
+	MaxIdleConns int
+}
+
+// endpoint is a validated EndpointConfig
+type endpoint struct {
+	config    EndpointConfig
+	tlsConfig *tls.Config
+}
+
+// endpointKey identifies an endpoint by its role and hostname, so that a source and a target on
+// the same instance keep their own settings
+type endpointKey struct {
+	role     string
+	hostname string
+}
+
+// Settings of the endpoints configured in this run, and the role of each token spec and hostname
+// they were configured with. A source and target sharing both token and hostname cannot be told
+// apart, so the endpoint configured last applies to both.
+var (
+	endpointsMu   sync.Mutex
+	endpoints     = make(map[endpointKey]*endpoint)
+	endpointRoles = make(map[tokenSource]string)
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Registers the connection settings of the "source" or "target" GitHub instance. Every client created
+// afterwards for the token and hostname uses them. The settings are validated up front so
+// mistakes fail before any request.
+func ConfigureEndpoint(role, hostname, token string, config EndpointConfig) error {
+	tlsConfig, err := buildTLSConfig(config)
+	if err != nil {
+		return err
+	}
+	switch config.Backoff {
+	case "", BackoffExponential, BackoffJitter:
+	default:
+		return fmt.Errorf("invalid backoff strategy %q (expected exponential or jitter)", config.Backoff)
+	}
+	if config.CallTimeout < 0 || config.RetryBudget < 0 || config.RetryDelay < 0 || config.RetryMaxDelay < 0 {
+		return fmt.Errorf("timeouts and retry delays cannot be negative")
+	}
+
+	endpointsMu.Lock()
+	endpoints[endpointKey{role, hostname}] = &endpoint{config: config, tlsConfig: tlsConfig}
+	if token != "" {
+		endpointRoles[tokenSource{spec: token, hostname: hostname}] = role
+	}
+	endpointsMu.Unlock()
+
+	// Clients built before the endpoint was configured would keep the old settings
+	clientsMu.Lock()
+	for key := range clients {
+		if key.hostname == hostname {
+			delete(clients, key)
+		}
+	}
+	clientsMu.Unlock()
+	return nil
+}
+
+// Builds the TLS configuration for an endpoint
+func buildTLSConfig(config EndpointConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if config.MinTLSVersion != "" {
+		version, ok := tlsVersions[config.MinTLSVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid minimum TLS version %q (expected 1.0, 1.1, 1.2, or 1.3)", config.MinTLSVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if config.CABundle != "" {
+		pem, err := os.ReadFile(config.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA bundle: %w", err)
+		}
+		// Trust the bundle in addition to the system roots, which proxies and GitHub.com still need
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s contains no PEM certificates", config.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCert != "" || config.ClientKey != "" {
+		if config.ClientCert == "" || config.ClientKey == "" {
+			return nil, fmt.Errorf("a client certificate needs both a certificate and a key file")
+		}
+		certificate, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	tlsConfig.InsecureSkipVerify = config.InsecureSkipVerify
+	return tlsConfig, nil
+}
+
+// Returns the role a token and hostname were configured for, or an empty string
+func endpointRole(token, hostname string) string {
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	return endpointRoles[tokenSource{spec: token, hostname: hostname}]
+}
+
+// Returns the TLS configuration registered for an endpoint, or nil for the defaults
+func endpointTLSConfig(key endpointKey) *tls.Config {
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	if registered, ok := endpoints[key]; ok {
+		return registered.tlsConfig.Clone()
+	}
+	return nil
+}
+
+// Returns the connection settings for an endpoint with defaults filled in. Retry settings that
+// are not set for the endpoint fall back to the global --retry-max and --retry-delay.
+func endpointSettings(key endpointKey) EndpointConfig {
+	endpointsMu.Lock()
+	var config EndpointConfig
+	if registered, ok := endpoints[key]; ok {
+		config = registered.config
+	}
+	endpointsMu.Unlock()
+
+	if config.CallTimeout == 0 {
+		config.CallTimeout = defaultCallTimeout
+	}
+	if config.RetryBudget == 0 {
+		config.RetryBudget = defaultRetryBudget
+	}
+	if config.RetryMax <= 0 {
+		config.RetryMax = viper.GetInt("RETRY_MAX")
+	}
+	if config.RetryMax <= 0 {
+		config.RetryMax = defaultRetryMax
+	}
+	if config.RetryDelay == 0 {
+		delay, err := time.ParseDuration(viper.GetString("RETRY_DELAY"))
+		if err != nil || delay <= 0 {
+			delay = defaultRetryDelay
+		}
+		config.RetryDelay = delay
+	}
+	if config.RetryMaxDelay == 0 {
+		config.RetryMaxDelay = defaultRetryMaxDelay
+	}
+	if config.Backoff == "" {
+		config.Backoff = BackoffExponential
+	}
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = defaultMaxIdleConns
+	}
+	return config
+}
+
+// Clients built in this run, keyed by token source and hostname, so connections are reused
+type clientKey struct {
+	source   tokenSource
+	hostname string
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[clientKey]*github.Client)
+	// clientEndpoints records the endpoint each cached client was built for
+	clientEndpoints = make(map[*github.Client]endpointKey)
+)
+
+// Returns the connection settings of the endpoint a client was built for
+func clientSettings(client *github.Client) EndpointConfig {
+	clientsMu.Lock()
+	key := clientEndpoints[client]
+	clientsMu.Unlock()
+	return endpointSettings(key)
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphQLError  `json:"errors"`
+}
+
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// Builds the GraphQL endpoint URL for github.com or a GitHub Enterprise Server API URL
+func graphQLEndpoint(hostname string) string {
+	if hostname == "" {
+		return "https://api.github.com/graphql"
+	}
+	// GHES serves GraphQL from /api/graphql rather than /api/v3/graphql
+	base := strings.TrimSuffix(hostname, "/")
+	base = strings.TrimSuffix(base, "/v3")
+	return base + "/graphql"
+}
+
+// Executes a GraphQL query and decodes the data portion of the response into out
+func executeGraphQLQuery(client *github.Client, hostname, query string, variables map[string]interface{}, out interface{}) error {
+	var resp graphQLResponse
+	err := retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+
+		// The request is rebuilt on every attempt since its body is consumed when sent
+		req, err := client.NewRequest("POST", graphQLEndpoint(hostname), &graphQLRequest{Query: query, Variables: variables})
+		if err != nil {
+			return fmt.Errorf("failed to build GraphQL request: %w", err)
+		}
+		_, apiErr := client.Do(ctx, req, &resp)
+		return apiErr
+	})
+	if err != nil {
+		return err
+	}
+
+	// GraphQL reports query errors with a 200 status, so they must be checked explicitly
+	if len(resp.Errors) > 0 {
+		messages := make([]string, 0, len(resp.Errors))
+		for _, gqlErr := range resp.Errors {
+			messages = append(messages, gqlErr.Message)
+		}
+		return fmt.Errorf("GraphQL query failed: %s", strings.Join(messages, "; "))
+	}
+
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		return fmt.Errorf("failed to decode GraphQL response: %w", err)
+	}
+	return nil
+}
+
+const enterpriseOrganizationsQuery = `query($slug: String!, $cursor: String) {
+  enterprise(slug: $slug) {
+    organizations(first: 100, after: $cursor) {
+      nodes { login }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`
+
+// Retrieves the login of every organization that belongs to an enterprise
+func FetchEnterpriseOrganizations(enterprise, token string, hostname ...string) ([]string, error) {
+	// Validate that the enterprise slug is provided
+	if enterprise == "" {
+		return nil, fmt.Errorf("enterprise slug is required")
+	}
+
+	// Initialize a new GitHub client
+	host := extractHostname(hostname...)
+	client, err := initializeGitHubClient(newClientConfig(token, host))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var organizations []string
+	variables := map[string]interface{}{"slug": enterprise}
+
+	// Page through the enterprise organizations until there are none left
+	for {
+		var data struct {
+			Enterprise *struct {
+				Organizations struct {
+					Nodes []struct {
+						Login string `json:"login"`
+					} `json:"nodes"`
+					PageInfo graphQLPageInfo `json:"pageInfo"`
+				} `json:"organizations"`
+			} `json:"enterprise"`
+		}
+		if err := executeGraphQLQuery(client, host, enterpriseOrganizationsQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("failed to fetch organizations for enterprise %s: %w", enterprise, err)
+		}
+		if data.Enterprise == nil {
+			return nil, fmt.Errorf("enterprise %s not found or not accessible", enterprise)
+		}
+
+		for _, node := range data.Enterprise.Organizations.Nodes {
+			if node.Login != "" {
+				organizations = append(organizations, node.Login)
+			}
+		}
+
+		if !data.Enterprise.Organizations.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = data.Enterprise.Organizations.PageInfo.EndCursor
+	}
+
+	return organizations, nil
+}
+
+const organizationRepositoriesQuery = `query($org: String!, $cursor: String) {
+  organization(login: $org) {
+    repositories(first: 100, after: $cursor) {
+      nodes {
+        databaseId
+        name
+        isArchived
+        visibility
+        defaultBranchRef { name }
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`
+
+// Retrieves the metadata of every repository in an organization through the GraphQL API,
+// requesting only the fields the repository catalog needs
+func fetchRepositoriesGraphQL(org, token string, hostname ...string) ([]Repository, error) {
+	// Validate that the organization name is provided
+	if org == "" {
+		return nil, fmt.Errorf("organization name is required")
+	}
+
+	// Initialize a new GitHub client
+	host := extractHostname(hostname...)
+	client, err := initializeGitHubClient(newClientConfig(token, host))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var repositories []Repository
+	variables := map[string]interface{}{"org": org}
+
+	// Page through the organization repositories until there are none left
+	for {
+		var data struct {
+			Organization *struct {
+				Repositories struct {
+					Nodes []struct {
+						DatabaseID       int64  `json:"databaseId"`
+						Name             string `json:"name"`
+						IsArchived       bool   `json:"isArchived"`
+						Visibility       string `json:"visibility"`
+						DefaultBranchRef *struct {
+							Name string `json:"name"`
+						} `json:"defaultBranchRef"`
+					} `json:"nodes"`
+					PageInfo graphQLPageInfo `json:"pageInfo"`
+				} `json:"repositories"`
+			} `json:"organization"`
+		}
+		if err := executeGraphQLQuery(client, host, organizationRepositoriesQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("failed to fetch repositories for organization %s: %w", org, err)
+		}
+		if data.Organization == nil {
+			return nil, fmt.Errorf("organization %s not found or not accessible", org)
+		}
+
+		for _, node := range data.Organization.Repositories.Nodes {
+			if node.Name == "" {
+				continue
+			}
+			repository := Repository{
+				ID:         node.DatabaseID,
+				Name:       node.Name,
+				Archived:   node.IsArchived,
+				Visibility: strings.ToLower(node.Visibility),
+			}
+			if node.DefaultBranchRef != nil {
+				repository.DefaultBranch = node.DefaultBranchRef.Name
+			}
+			repositories = append(repositories, repository)
+		}
+
+		if !data.Organization.Repositories.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = data.Organization.Repositories.PageInfo.EndCursor
+	}
+
+	return repositories, nil
+}
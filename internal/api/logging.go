@@ -0,0 +1,45 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+)
+
+// loggingTransport logs every API call with its status, duration, remaining rate limit, and
+// request ID. Headers and bodies are never logged, so tokens and variable values stay out of the log.
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	duration := time.Since(start)
+
+	attrs := []any{
+		slog.String("method", req.Method),
+		slog.String("host", req.URL.Host),
+		slog.String("path", req.URL.Path),
+		slog.Int64("duration_ms", duration.Milliseconds()),
+	}
+	if err != nil {
+		slog.Warn("API call failed", append(attrs, slog.String("error", logging.Redact(err.Error())))...)
+		return resp, err
+	}
+
+	attrs = append(attrs,
+		slog.Int("status", resp.StatusCode),
+		slog.String("rate_limit_remaining", resp.Header.Get("X-RateLimit-Remaining")),
+		slog.String("request_id", resp.Header.Get("X-GitHub-Request-Id")),
+	)
+	level := slog.LevelInfo
+	// Not found is expected while checking whether variables and repositories exist
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		level = slog.LevelWarn
+	}
+	slog.Log(req.Context(), level, "API call", attrs...)
+	return resp, nil
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// Kinds of GitHub tokens, told apart by their prefix
+const (
+	TokenTypeClassic         = "personal access token (classic)"
+	TokenTypeFineGrained     = "fine-grained personal access token"
+	TokenTypeAppInstallation = "GitHub App installation token"
+	TokenTypeAppUser         = "GitHub App user token"
+	TokenTypeOAuth           = "OAuth app token"
+	TokenTypeUnknown         = "unknown token type"
+)
+
+// TokenInfo describes the identity and OAuth scopes behind a token
+type TokenInfo struct {
+	Type  string
+	Login string
+	// Scopes lists the X-OAuth-Scopes of classic and OAuth tokens. Fine-grained and App tokens have none.
+	Scopes    []string
+	HasScopes bool
+}
+
+// Broader OAuth scopes that include narrower ones
+var impliedScopes = map[string][]string{
+	"admin:org": {"write:org", "read:org"},
+	"write:org": {"read:org"},
+	"repo":      {"public_repo"},
+}
+
+// Reports whether the token has an OAuth scope, directly or through a broader scope
+func (t *TokenInfo) HasScope(scope string) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope {
+			return true
+		}
+		for _, implied := range impliedScopes[granted] {
+			if implied == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the kind of a token from its prefix
+func tokenType(token string) string {
+	switch {
+	case strings.HasPrefix(token, "ghp_"):
+		return TokenTypeClassic
+	case strings.HasPrefix(token, "github_pat_"):
+		return TokenTypeFineGrained
+	case strings.HasPrefix(token, "ghs_"):
+		return TokenTypeAppInstallation
+	case strings.HasPrefix(token, "ghu_"):
+		return TokenTypeAppUser
+	case strings.HasPrefix(token, "gho_"):
+		return TokenTypeOAuth
+	}
+	return TokenTypeUnknown
+}
+
+// Retrieves the user and OAuth scopes behind a token from the X-OAuth-Scopes header.
+// App installation tokens do not belong to a user, so only their type is reported.
+func FetchTokenInfo(token string, hostname ...string) (*TokenInfo, error) {
+	resolved, err := ResolveToken(token, extractHostname(hostname...))
+	if err != nil {
+		return nil, err
+	}
+	info := &TokenInfo{Type: tokenType(resolved)}
+	if info.Type == TokenTypeAppInstallation {
+		return info, nil
+	}
+
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var user *github.User
+	var header string
+	// Retry the authenticated user retrieval operation
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		var resp *github.Response
+		var apiErr error
+		user, resp, apiErr = client.Users.Get(ctx, "")
+		if resp != nil {
+			header = resp.Header.Get("X-OAuth-Scopes")
+			info.HasScopes = resp.Header.Values("X-OAuth-Scopes") != nil
+		}
+		return apiErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	info.Login = user.GetLogin()
+	for _, scope := range strings.Split(header, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			info.Scopes = append(info.Scopes, scope)
+		}
+	}
+	return info, nil
+}
+
+// Retrieves the role ("admin" or "member") of the token's user in an organization
+func FetchOrgRole(org, token string, hostname ...string) (string, error) {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var membership *github.Membership
+	// Retry the membership retrieval operation
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		var apiErr error
+		membership, _, apiErr = client.Organizations.GetOrgMembership(ctx, "", org)
+		return apiErr
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch membership in %s: %w", org, err)
+	}
+	if membership.GetState() != "active" {
+		return "", fmt.Errorf("membership in %s is %s", org, membership.GetState())
+	}
+	return membership.GetRole(), nil
+}
+
+// Checks that the token can list the variables of an organization, reading a single item
+func ProbeOrgVariables(org, token string, hostname ...string) error {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	// Retry the variable listing operation
+	return retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		_, _, err := client.Actions.ListOrgVariables(ctx, org, &github.ListOptions{PerPage: 1})
+		return err
+	})
+}
+
+// Checks that the token can create and delete variables in a repository by writing a
+// temporary variable and removing it again
+func ProbeRepoVariableWrite(org, repo, token string, hostname ...string) error {
+	name := fmt.Sprintf("GHMV_DOCTOR_CHECK_%d", time.Now().Unix())
+	if err := AddRepoVariable(org, repo, name, "gh-migrate-variables access check", "", token, hostname...); err != nil {
+		return err
+	}
+	if err := DeleteRepoVariable(org, repo, name, token, hostname...); err != nil {
+		return fmt.Errorf("temporary variable %s was created but could not be deleted: %w", name, err)
+	}
+	return nil
+}
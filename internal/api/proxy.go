@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/http/httpproxy"
+)
+
+type ProxyConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	// Username and Password authenticate with proxies whose URL carries no credentials
+	Username string
+	Password string
+}
+
+// Creates a proxy function based on the provided ProxyConfig. NO_PROXY follows the rules of
+// golang.org/x/net/http/httpproxy: "*" bypasses every host, "example.com" also matches its
+// subdomains, ".example.com" only matches subdomains, host:port entries match that port only,
+// and IP addresses and CIDR ranges match requests to those addresses. Proxy URLs may use the
+// http, https, socks5, or socks5h schemes.
+func buildProxyFunction(proxyConfig *ProxyConfig) func(*http.Request) (*url.URL, error) {
+	if proxyConfig == nil {
+		return func(*http.Request) (*url.URL, error) { return nil, nil }
+	}
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  proxyConfig.HTTPProxy,
+		HTTPSProxy: proxyConfig.HTTPSProxy,
+		NoProxy:    proxyConfig.NoProxy,
+	}).ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxyFunc(req.URL)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+		// Credentials in the proxy URL take precedence over the separate username and password
+		if proxyURL.User == nil && proxyConfig.Username != "" {
+			proxyURL.User = url.UserPassword(proxyConfig.Username, proxyConfig.Password)
+		}
+		return proxyURL, nil
+	}
+}
+
+// Retrieves proxy configuration from flags and environment variables. The lowercase
+// http_proxy, https_proxy, and no_proxy variables are honored as well.
+func loadProxyConfigFromEnv() *ProxyConfig {
+	return &ProxyConfig{
+		HTTPProxy:  proxySetting("HTTP_PROXY"),
+		HTTPSProxy: proxySetting("HTTPS_PROXY"),
+		NoProxy:    proxySetting("NO_PROXY"),
+		Username:   viper.GetString("PROXY_USERNAME"),
+		Password:   viper.GetString("PROXY_PASSWORD"),
+	}
+}
+
+func proxySetting(name string) string {
+	if value := viper.GetString(name); value != "" {
+		return value
+	}
+	return os.Getenv(strings.ToLower(name))
+}
+
+// Returns the proxy requests to a GitHub API endpoint go through, or nil when they connect
+// directly. Credentials are removed so the result can be printed.
+func ProxyForEndpoint(hostname string) (*url.URL, error) {
+	endpoint := hostname
+	if endpoint == "" {
+		endpoint = "https://api.github.com"
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: %w", endpoint, err)
+	}
+	proxyURL, err := buildProxyFunction(loadProxyConfigFromEnv())(req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy configuration: %w", err)
+	}
+	if proxyURL != nil && proxyURL.User != nil {
+		redacted := *proxyURL
+		redacted.User = url.User(proxyURL.User.Username())
+		proxyURL = &redacted
+	}
+	return proxyURL, nil
+}
@@ -0,0 +1,430 @@
+package api
+
+import (
+	crypto_rand "crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Separator used to join selected repository names into a single CSV field
+const SelectedReposSeparator = ";"
+
+// Secret stores. Each store keeps its own secrets and public keys.
+const (
+	SecretStoreActions    = "actions"
+	SecretStoreDependabot = "dependabot"
+	SecretStoreCodespaces = "codespaces"
+)
+
+// Checks that a secret store is known and that the entity type is supported by it.
+// Only Actions has environment-level secrets.
+func validateSecretStore(store, entityType string) error {
+	switch store {
+	case SecretStoreActions:
+		return nil
+	case SecretStoreDependabot, SecretStoreCodespaces:
+		if entityType == EntityTypeEnvironment {
+			return fmt.Errorf("%s secrets cannot be scoped to an environment", store)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown secret store %q (expected actions, dependabot, or codespaces)", store)
+}
+
+// Parses a GitHub secret into a map representation. Secret values cannot be read back from the API.
+func parseGitHubSecret(secret *github.Secret, store, scope, env string) map[string]string {
+	// Return nil if the secret is nil or has no name
+	if secret == nil || secret.Name == "" {
+		return nil
+	}
+
+	parsedSecret := map[string]string{
+		"Name":        secret.Name,
+		"Scope":       scope,
+		"Environment": env,
+		"Visibility":  secret.Visibility,
+		"Store":       store,
+	}
+	// Repository and environment secrets have no visibility of their own
+	if parsedSecret["Visibility"] == "" && scope == EntityTypeOrg {
+		parsedSecret["Visibility"] = defaultVariableVisibility
+	}
+	return parsedSecret
+}
+
+// Retrieves secret names from an Actions, Dependabot, or Codespaces store of a GitHub organization, repository, or environment
+func fetchGitHubSecrets(store, entityType, org, repo, env, token string, hostname ...string) ([]map[string]string, error) {
+	if err := validateSecretStore(store, entityType); err != nil {
+		return nil, err
+	}
+	// Validate that the organization name is provided
+	if org == "" {
+		return nil, fmt.Errorf("organization name is required")
+	}
+	// Validate that the repository name is provided for repository-level secrets
+	if entityType != EntityTypeOrg && repo == "" {
+		return nil, fmt.Errorf("repository name is required")
+	}
+	// Validate that the environment name is provided for environment-level secrets
+	if entityType == EntityTypeEnvironment && env == "" {
+		return nil, fmt.Errorf("environment name is required")
+	}
+
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	// Environment secrets are addressed by repository ID
+	var repoID int64
+	if entityType == EntityTypeEnvironment {
+		repoID, err = FetchRepositoryID(org, repo, token, hostname...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Request the maximum page size and follow pagination so no secrets are missed
+	opts := &github.ListOptions{PerPage: 100}
+	var allSecrets []*github.Secret
+	for {
+		var secrets *github.Secrets
+		var resp *github.Response
+		// Retry the secret retrieval operation
+		err = retryWithDefaultContext(client, func() error {
+			ctx, cancel := createAPITimeoutContext(client)
+			defer cancel()
+			var apiErr error
+
+			switch {
+			case store == SecretStoreDependabot && entityType == EntityTypeOrg:
+				secrets, resp, apiErr = client.Dependabot.ListOrgSecrets(ctx, org, opts)
+			case store == SecretStoreDependabot:
+				secrets, resp, apiErr = client.Dependabot.ListRepoSecrets(ctx, org, repo, opts)
+			case store == SecretStoreCodespaces && entityType == EntityTypeOrg:
+				secrets, resp, apiErr = client.Codespaces.ListOrgSecrets(ctx, org, opts)
+			case store == SecretStoreCodespaces:
+				secrets, resp, apiErr = client.Codespaces.ListRepoSecrets(ctx, org, repo, opts)
+			case entityType == EntityTypeOrg:
+				secrets, resp, apiErr = client.Actions.ListOrgSecrets(ctx, org, opts)
+			case entityType == EntityTypeEnvironment:
+				secrets, resp, apiErr = client.Actions.ListEnvSecrets(ctx, int(repoID), env, opts)
+			default:
+				secrets, resp, apiErr = client.Actions.ListRepoSecrets(ctx, org, repo, opts)
+			}
+			return apiErr
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s %s secrets: %w", entityType, store, err)
+		}
+
+		if secrets == nil {
+			return nil, fmt.Errorf("no secrets data returned for %s %s", entityType, org)
+		}
+		allSecrets = append(allSecrets, secrets.Secrets...)
+
+		// If there are no more pages, stop paginating
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	scope := entityType
+	if entityType != EntityTypeOrg {
+		scope = repo
+	}
+
+	var parsedSecrets []map[string]string
+	for _, secret := range allSecrets {
+		parsedSecret := parseGitHubSecret(secret, store, scope, env)
+		if parsedSecret == nil {
+			continue
+		}
+		// Record which repositories can use organization secrets with selected visibility
+		if entityType == EntityTypeOrg && parsedSecret["Visibility"] == "selected" {
+			repos, err := fetchSelectedReposForOrgSecret(client, store, org, secret.Name)
+			if err != nil {
+				return nil, err
+			}
+			parsedSecret["SelectedRepositories"] = strings.Join(repos, SelectedReposSeparator)
+		}
+		parsedSecrets = append(parsedSecrets, parsedSecret)
+	}
+
+	return parsedSecrets, nil
+}
+
+// Retrieves the names of the repositories selected for an organization secret
+func fetchSelectedReposForOrgSecret(client *github.Client, store, org, name string) ([]string, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	var repos []string
+	for {
+		var selected *github.SelectedReposList
+		var resp *github.Response
+		// Retry the selected repository retrieval operation
+		err := retryWithDefaultContext(client, func() error {
+			ctx, cancel := createAPITimeoutContext(client)
+			defer cancel()
+			var apiErr error
+			switch store {
+			case SecretStoreDependabot:
+				selected, resp, apiErr = client.Dependabot.ListSelectedReposForOrgSecret(ctx, org, name, opts)
+			case SecretStoreCodespaces:
+				selected, resp, apiErr = client.Codespaces.ListSelectedReposForOrgSecret(ctx, org, name, opts)
+			default:
+				selected, resp, apiErr = client.Actions.ListSelectedReposForOrgSecret(ctx, org, name, opts)
+			}
+			return apiErr
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch selected repositories for secret %s: %w", name, err)
+		}
+
+		if selected != nil {
+			for _, repo := range selected.Repositories {
+				if repo != nil && repo.Name != nil {
+					repos = append(repos, *repo.Name)
+				}
+			}
+		}
+
+		// If there are no more pages, stop paginating
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return repos, nil
+}
+
+// Retrieves organization-level secret names from a GitHub secret store
+func FetchOrgSecrets(store, org, token string, hostname ...string) ([]map[string]string, error) {
+	// Calls fetchGitHubSecrets for organization-level secrets
+	return fetchGitHubSecrets(store, EntityTypeOrg, org, "", "", token, hostname...)
+}
+
+// Retrieves repository-level secret names from a GitHub secret store
+func FetchRepoSecrets(store, org, repo, token string, hostname ...string) ([]map[string]string, error) {
+	// Calls fetchGitHubSecrets for repository-level secrets
+	return fetchGitHubSecrets(store, EntityTypeRepository, org, repo, "", token, hostname...)
+}
+
+// Retrieves environment-level Actions secret names from GitHub
+func FetchEnvSecrets(org, repo, env, token string, hostname ...string) ([]map[string]string, error) {
+	// Calls fetchGitHubSecrets for environment-level secrets
+	return fetchGitHubSecrets(SecretStoreActions, EntityTypeEnvironment, org, repo, env, token, hostname...)
+}
+
+// Retrieves the names of the deployment environments in a repository
+func FetchEnvironments(org, repo, token string, hostname ...string) ([]string, error) {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	opts := &github.EnvironmentListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	var environments []string
+	for {
+		var envs *github.EnvResponse
+		var resp *github.Response
+		// Retry the environment retrieval operation
+		err = retryWithDefaultContext(client, func() error {
+			ctx, cancel := createAPITimeoutContext(client)
+			defer cancel()
+			var apiErr error
+			envs, resp, apiErr = client.Repositories.ListEnvironments(ctx, org, repo, opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch environments for %s/%s: %w", org, repo, err)
+		}
+
+		if envs != nil {
+			for _, env := range envs.Environments {
+				if env != nil && env.Name != nil {
+					environments = append(environments, *env.Name)
+				}
+			}
+		}
+
+		// If there are no more pages, stop paginating
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return environments, nil
+}
+
+// Encrypts a secret value with a repository or organization public key using a libsodium sealed box
+func encryptSecretValue(publicKey *github.PublicKey, value string) (string, error) {
+	decodedKey, err := base64.StdEncoding.DecodeString(publicKey.GetKey())
+	if err != nil {
+		return "", fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(decodedKey) != 32 {
+		return "", fmt.Errorf("unexpected public key length %d", len(decodedKey))
+	}
+
+	var key [32]byte
+	copy(key[:], decodedKey)
+	encrypted, err := box.SealAnonymous(nil, []byte(value), &key, crypto_rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt secret value: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// Creates or updates a secret in an Actions, Dependabot, or Codespaces store of a GitHub organization, repository, or environment
+func setGitHubSecret(store, entityType, org, repo, env, name, value, visibility string, selectedRepos []string, token string, hostname ...string) error {
+	if err := validateSecretStore(store, entityType); err != nil {
+		return err
+	}
+	// Validate that the organization name and secret name are provided
+	if org == "" || name == "" {
+		return fmt.Errorf("organization name and secret name are required")
+	}
+	// Validate that the repository name is provided for repository-level secrets
+	if entityType != EntityTypeOrg && repo == "" {
+		return fmt.Errorf("repository name is required")
+	}
+	// Validate that the environment name is provided for environment-level secrets
+	if entityType == EntityTypeEnvironment && env == "" {
+		return fmt.Errorf("environment name is required")
+	}
+
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	// Environment secrets are addressed by repository ID
+	var repoID int64
+	if entityType == EntityTypeEnvironment {
+		repoID, err = FetchRepositoryID(org, repo, token, hostname...)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Retrieve the public key the secret value must be encrypted with
+	var publicKey *github.PublicKey
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		var apiErr error
+
+		switch {
+		case store == SecretStoreDependabot && entityType == EntityTypeOrg:
+			publicKey, _, apiErr = client.Dependabot.GetOrgPublicKey(ctx, org)
+		case store == SecretStoreDependabot:
+			publicKey, _, apiErr = client.Dependabot.GetRepoPublicKey(ctx, org, repo)
+		case store == SecretStoreCodespaces && entityType == EntityTypeOrg:
+			publicKey, _, apiErr = client.Codespaces.GetOrgPublicKey(ctx, org)
+		case store == SecretStoreCodespaces:
+			publicKey, _, apiErr = client.Codespaces.GetRepoPublicKey(ctx, org, repo)
+		case entityType == EntityTypeOrg:
+			publicKey, _, apiErr = client.Actions.GetOrgPublicKey(ctx, org)
+		case entityType == EntityTypeEnvironment:
+			publicKey, _, apiErr = client.Actions.GetEnvPublicKey(ctx, int(repoID), env)
+		default:
+			publicKey, _, apiErr = client.Actions.GetRepoPublicKey(ctx, org, repo)
+		}
+		return apiErr
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s %s public key: %w", entityType, store, err)
+	}
+
+	encryptedValue, err := encryptSecretValue(publicKey, value)
+	if err != nil {
+		return err
+	}
+
+	secret := &github.EncryptedSecret{
+		Name:           name,
+		KeyID:          publicKey.GetKeyID(),
+		EncryptedValue: encryptedValue,
+	}
+	if entityType == EntityTypeOrg {
+		if visibility == "" {
+			visibility = defaultVariableVisibility
+		}
+		secret.Visibility = visibility
+		// The API takes repository IDs, so resolve each selected repository name first
+		if visibility == "selected" {
+			for _, selectedRepo := range selectedRepos {
+				id, err := FetchRepositoryID(org, selectedRepo, token, hostname...)
+				if err != nil {
+					return err
+				}
+				secret.SelectedRepositoryIDs = append(secret.SelectedRepositoryIDs, id)
+			}
+		}
+	}
+
+	// Retry the secret creation operation
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+
+		// Dependabot uses its own request type for the same fields
+		dependabotSecret := &github.DependabotEncryptedSecret{
+			Name:                  secret.Name,
+			KeyID:                 secret.KeyID,
+			EncryptedValue:        secret.EncryptedValue,
+			Visibility:            secret.Visibility,
+			SelectedRepositoryIDs: github.DependabotSecretsSelectedRepoIDs(secret.SelectedRepositoryIDs),
+		}
+
+		var err error
+		switch {
+		case store == SecretStoreDependabot && entityType == EntityTypeOrg:
+			_, err = client.Dependabot.CreateOrUpdateOrgSecret(ctx, org, dependabotSecret)
+		case store == SecretStoreDependabot:
+			_, err = client.Dependabot.CreateOrUpdateRepoSecret(ctx, org, repo, dependabotSecret)
+		case store == SecretStoreCodespaces && entityType == EntityTypeOrg:
+			_, err = client.Codespaces.CreateOrUpdateOrgSecret(ctx, org, secret)
+		case store == SecretStoreCodespaces:
+			_, err = client.Codespaces.CreateOrUpdateRepoSecret(ctx, org, repo, secret)
+		case entityType == EntityTypeOrg:
+			_, err = client.Actions.CreateOrUpdateOrgSecret(ctx, org, secret)
+		case entityType == EntityTypeEnvironment:
+			_, err = client.Actions.CreateOrUpdateEnvSecret(ctx, int(repoID), env, secret)
+		default:
+			_, err = client.Actions.CreateOrUpdateRepoSecret(ctx, org, repo, secret)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s %s secret %s: %w", entityType, store, name, err)
+	}
+
+	return nil
+}
+
+// Creates or updates an organization-level secret in a GitHub secret store
+func SetOrgSecret(store, org, name, value, visibility string, selectedRepos []string, token string, hostname ...string) error {
+	// Calls setGitHubSecret for an organization-level secret
+	return setGitHubSecret(store, EntityTypeOrg, org, "", "", name, value, visibility, selectedRepos, token, hostname...)
+}
+
+// Creates or updates a repository-level secret in a GitHub secret store
+func SetRepoSecret(store, org, repo, name, value, token string, hostname ...string) error {
+	// Calls setGitHubSecret for a repository-level secret
+	return setGitHubSecret(store, EntityTypeRepository, org, repo, "", name, value, "", nil, token, hostname...)
+}
+
+// Creates or updates an environment-level Actions secret in GitHub
+func SetEnvSecret(org, repo, env, name, value, token string, hostname ...string) error {
+	// Calls setGitHubSecret for an environment-level secret
+	return setGitHubSecret(SecretStoreActions, EntityTypeEnvironment, org, repo, env, name, value, "", nil, token, hostname...)
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Prefixes that select a token provider instead of a literal token
+const (
+	TokenSourceGH      = "gh"
+	TokenSourceFile    = "file:"
+	TokenSourceFD      = "fd:"
+	TokenSourceCommand = "cmd:"
+)
+
+// Tokens already resolved in this run, keyed by token spec. Only the gh spec depends on the
+// hostname, so it is also keyed by hostname. Providers such as commands and file descriptors are
+// only consulted once, even when source and target share a spec.
+var (
+	tokensMu    sync.Mutex
+	tokensCache = make(map[string]string)
+)
+
+// tokenSource resolves a token spec on first use
+type tokenSource struct {
+	spec     string
+	hostname string
+}
+
+// Returns the resolved token as an OAuth2 token
+func (s tokenSource) Token() (*oauth2.Token, error) {
+	token, err := ResolveToken(s.spec, s.hostname)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: token}, nil
+}
+
+// Returns a token source for a token spec:
+//
+//	gh            the token of `gh auth token --hostname <host>`
+//	gh:<host>     the token gh holds for another host
+//	file:<path>   the first line of a file
+//	fd:<n>        the first line read from an open file descriptor
+//	cmd:<command> the output of a shell command, such as a vault CLI
+//
+// Anything else is used as the token itself.
+func NewTokenSource(spec, hostname string) oauth2.TokenSource {
+	return tokenSource{spec: spec, hostname: hostname}
+}
+
+// Builds the client configuration for a token spec and hostname, with the role the endpoint was
+// configured for
+func newClientConfig(token, hostname string) GitHubClientConfig {
+	if token == "" {
+		return GitHubClientConfig{Hostname: hostname}
+	}
+	return GitHubClientConfig{TokenSource: NewTokenSource(token, hostname), Hostname: hostname, Role: endpointRole(token, hostname)}
+}
+
+// Resolves a token spec into a token, running its provider on first use
+func ResolveToken(spec, hostname string) (string, error) {
+	if !isTokenProvider(spec) {
+		return spec, nil
+	}
+
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	key := spec
+	if spec == TokenSourceGH {
+		key += "\x00" + hostname
+	}
+	if token, ok := tokensCache[key]; ok {
+		return token, nil
+	}
+
+	var token string
+	var err error
+	switch {
+	case spec == TokenSourceGH:
+		token, err = ghAuthToken(apiHost(hostname))
+	case strings.HasPrefix(spec, TokenSourceGH+":"):
+		token, err = ghAuthToken(strings.TrimPrefix(spec, TokenSourceGH+":"))
+	case strings.HasPrefix(spec, TokenSourceFile):
+		token, err = readTokenFile(strings.TrimPrefix(spec, TokenSourceFile))
+	case strings.HasPrefix(spec, TokenSourceFD):
+		token, err = readTokenFD(strings.TrimPrefix(spec, TokenSourceFD))
+	case strings.HasPrefix(spec, TokenSourceCommand):
+		token, err = runTokenCommand(strings.TrimPrefix(spec, TokenSourceCommand))
+	}
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("token source %s returned an empty token", describeTokenSpec(spec))
+	}
+	tokensCache[key] = token
+	return token, nil
+}
+
+// Reports whether a token spec names a provider rather than being a token
+func isTokenProvider(spec string) bool {
+	return spec == TokenSourceGH ||
+		strings.HasPrefix(spec, TokenSourceGH+":") ||
+		strings.HasPrefix(spec, TokenSourceFile) ||
+		strings.HasPrefix(spec, TokenSourceFD) ||
+		strings.HasPrefix(spec, TokenSourceCommand)
+}
+
+// Describes a token spec for messages without revealing a literal token
+func describeTokenSpec(spec string) string {
+	switch {
+	case spec == TokenSourceGH || strings.HasPrefix(spec, TokenSourceGH+":"),
+		strings.HasPrefix(spec, TokenSourceFile),
+		strings.HasPrefix(spec, TokenSourceFD):
+		return spec
+	case strings.HasPrefix(spec, TokenSourceCommand):
+		return "command"
+	}
+	return "token"
+}
+
+// Returns the bare host of an API URL such as https://github.example.com/api/v3
+func apiHost(hostname string) string {
+	if hostname == "" {
+		return "github.com"
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(hostname, "https://"), "http://")
+	host = strings.TrimSuffix(host, "/")
+	host = strings.TrimSuffix(host, "/api/v3")
+	return host
+}
+
+// Reads the token gh holds for a host
+func ghAuthToken(host string) (string, error) {
+	output, err := exec.Command("gh", "auth", "token", "--hostname", host).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get token from gh auth token --hostname %s: %w", host, commandError(err))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Reads a token from the first line of a file
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	return firstLine(string(data)), nil
+}
+
+// Reads a token from an open file descriptor, such as one passed with 3< token.txt
+func readTokenFD(value string) (string, error) {
+	fd, err := strconv.Atoi(value)
+	if err != nil || fd < 0 {
+		return "", fmt.Errorf("invalid token file descriptor %q", value)
+	}
+	file := os.NewFile(uintptr(fd), "fd"+value)
+	if file == nil {
+		return "", fmt.Errorf("invalid token file descriptor %q", value)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token from file descriptor %d: %w", fd, err)
+	}
+	return firstLine(string(data)), nil
+}
+
+// Runs a shell command and uses its output as the token
+func runTokenCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("token command failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Adds the standard error of a failed command to its error
+func commandError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+// Returns the first line of a string without surrounding whitespace
+func firstLine(value string) string {
+	value = strings.TrimSpace(value)
+	if i := strings.IndexAny(value, "\r\n"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// WorkflowsPath is the directory GitHub Actions reads workflow files from
+const WorkflowsPath = ".github/workflows"
+
+// WorkflowFile is a workflow file read from a repository
+type WorkflowFile struct {
+	Path    string
+	Content string
+}
+
+// Reports whether a file name is a workflow file
+func IsWorkflowFile(name string) bool {
+	return strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml")
+}
+
+// Retrieves the workflow files of a repository's default branch through the contents API.
+// Repositories without workflows, including empty repositories, have none.
+func FetchWorkflowFiles(org, repo, token string, hostname ...string) ([]WorkflowFile, error) {
+	// Initialize a new GitHub client
+	client, err := initializeGitHubClient(newClientConfig(token, extractHostname(hostname...)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var entries []*github.RepositoryContent
+	// Retry the workflow directory listing operation, a missing directory is not retried
+	err = retryWithDefaultContext(client, func() error {
+		ctx, cancel := createAPITimeoutContext(client)
+		defer cancel()
+		var apiErr error
+		_, entries, _, apiErr = client.Repositories.GetContents(ctx, org, repo, WorkflowsPath, nil)
+		if IsNotFound(apiErr) {
+			entries = nil
+			return nil
+		}
+		return apiErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflows of %s: %w", repo, err)
+	}
+
+	var files []WorkflowFile
+	for _, entry := range entries {
+		if entry.GetType() != "file" || !IsWorkflowFile(entry.GetName()) {
+			continue
+		}
+		var file *github.RepositoryContent
+		// Retry the workflow file retrieval operation
+		err = retryWithDefaultContext(client, func() error {
+			ctx, cancel := createAPITimeoutContext(client)
+			defer cancel()
+			var apiErr error
+			file, _, _, apiErr = client.Repositories.GetContents(ctx, org, repo, path.Join(WorkflowsPath, entry.GetName()), nil)
+			return apiErr
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read workflow %s of %s: %w", entry.GetName(), repo, err)
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode workflow %s of %s: %w", entry.GetName(), repo, err)
+		}
+		files = append(files, WorkflowFile{Path: file.GetPath(), Content: content})
+	}
+	return files, nil
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Attribute keys whose values are never written to the log
+var sensitiveKeys = map[string]bool{
+	"token":         true,
+	"value":         true,
+	"password":      true,
+	"authorization": true,
+	"secret":        true,
+}
+
+// GitHubTokenPattern matches GitHub token formats. They are removed from any logged text such as
+// error messages, and the secret scan flags variable values that contain them.
+var GitHubTokenPattern = regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)
+
+const redacted = "[REDACTED]"
+
+var (
+	// logFile is the log file opened by Setup, if any
+	logFile io.Closer
+	// command is the command whose start was logged
+	command string
+)
+
+// Setup configures the default slog logger. Logs go to the log file when one is given, at info
+// level unless another level is set. Without a log file they only go to stderr when a level is
+// set, since the console already shows warnings and errors and would show them twice.
+func Setup(level, format, file string) error {
+	var writer io.Writer = io.Discard
+	logLevel := slog.LevelInfo
+	if file != "" {
+		opened, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("cannot open log file %s: %w", file, err)
+		}
+		writer, logFile = opened, opened
+	} else if level != "" {
+		writer = os.Stderr
+	}
+
+	if level != "" {
+		if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("invalid log level %q (expected debug, info, warn, or error)", level)
+		}
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: redactAttr}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(writer, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(writer, options)
+	default:
+		return fmt.Errorf("invalid log format %q (expected text or json)", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Started logs the start of a command
+func Started(name string) {
+	command = name
+	slog.Info("command started", "command", name)
+}
+
+// Finish logs the end of the command with its exit code and closes the log file
+func Finish(code int) {
+	slog.Info("command finished", "command", command, "exit_code", code)
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+}
+
+// Exit finishes the log and exits with the given code. Commands exit through it instead of
+// os.Exit, which would skip the end of the log.
+func Exit(code int) {
+	Finish(code)
+	os.Exit(code)
+}
+
+// Redacts sensitive attributes and any tokens in string attributes
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	if attr.Value.Kind() == slog.KindString {
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	}
+	if err, ok := attr.Value.Any().(error); ok {
+		return slog.String(attr.Key, Redact(err.Error()))
+	}
+	return attr
+}
+
+// Redact removes GitHub tokens from text
+func Redact(text string) string {
+	return GitHubTokenPattern.ReplaceAllString(text, redacted)
+}
@@ -0,0 +1,198 @@
+package apply
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/mona-actions/gh-migrate-variables/pkg/preflight"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// ApplyDesiredState reconciles a target organization with a declarative desired state file
+func ApplyDesiredState() error {
+	start := time.Now()
+
+	stateFile := viper.GetString("file")
+	hostname := viper.GetString("target-hostname")
+	targetToken := viper.GetString("target-token")
+	prune := viper.GetBool("GHMV_PRUNE")
+	dryRun := viper.GetBool("GHMV_DRY_RUN")
+	autoApprove := viper.GetBool("GHMV_AUTO_APPROVE")
+
+	if stateFile == "" || targetToken == "" {
+		return fmt.Errorf("missing required parameters: desired state file or target token")
+	}
+
+	state, err := loadDesiredState(stateFile)
+	if err != nil {
+		return err
+	}
+
+	// The command line organization overrides the one in the file
+	targetOrg := viper.GetString("target-organization")
+	if targetOrg == "" {
+		targetOrg = state.Organization
+	}
+	if targetOrg == "" {
+		return fmt.Errorf("missing target organization: set organization in %s or pass --target-organization", stateFile)
+	}
+
+	// Check that the target instance supports the declared variables before reading anything
+	required := []string{api.FeatureActionsVariables}
+	for _, repo := range state.Repositories {
+		if len(repo.Environments) > 0 {
+			required = append(required, api.FeatureEnvironmentVariables)
+			break
+		}
+	}
+	if _, err := preflight.Check(targetToken, hostname, required, nil); err != nil {
+		return err
+	}
+	if err := preflight.CheckAccess(preflight.Endpoint{
+		Label:         "Target",
+		Token:         targetToken,
+		Hostname:      hostname,
+		Organizations: []string{targetOrg},
+		Access:        preflight.AccessWrite,
+		OrgWrite:      state.Variables != nil,
+	}); err != nil {
+		return err
+	}
+
+	pterm.Info.Println("Reading current variables...")
+	changes, err := buildPlan(state, targetOrg, targetToken, hostname, prune)
+	if err != nil {
+		return err
+	}
+
+	adds, updates, deletes := printPlan(changes, targetOrg)
+	if len(changes) == 0 {
+		return nil
+	}
+	if dryRun {
+		output.Message("🔍", "Dry run: no changes were made.")
+		return nil
+	}
+
+	if !autoApprove {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("apply requires confirmation, re-run with --auto-approve to apply without prompting")
+		}
+		confirmed, err := pterm.DefaultInteractiveConfirm.Show("Apply these changes?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("apply cancelled, no variables were changed")
+		}
+	}
+
+	var stats struct {
+		created int
+		updated int
+		deleted int
+		failed  int
+	}
+
+	output.Group("Applying changes")
+	ensuredEnvironments := make(map[string]bool)
+	for _, c := range changes {
+		// Environments must exist before variables can be created in them
+		if c.entity == api.EntityTypeEnvironment && c.action == actionCreate && !ensuredEnvironments[c.repo+"/"+c.env] {
+			if err := api.EnsureEnvironment(targetOrg, c.repo, c.env, targetToken, hostname); err != nil {
+				output.Error("Error creating environment %s in %s: %v", c.env, c.repo, err)
+				output.Event("change", map[string]any{"name": c.name, "location": c.scope(targetOrg), "action": c.action, "outcome": "failed"})
+				stats.failed++
+				continue
+			}
+			ensuredEnvironments[c.repo+"/"+c.env] = true
+		}
+
+		if err := applyChange(c, targetOrg, targetToken, hostname); err != nil {
+			output.Error("Error applying %s of %s in %s: %v", c.action, c.name, c.scope(targetOrg), err)
+			output.Event("change", map[string]any{"name": c.name, "location": c.scope(targetOrg), "action": c.action, "outcome": "failed"})
+			stats.failed++
+			continue
+		}
+		output.Event("change", map[string]any{"name": c.name, "location": c.scope(targetOrg), "action": c.action, "outcome": "applied"})
+
+		switch c.action {
+		case actionCreate:
+			pterm.Success.Printf("Created %s in %s\n", c.name, c.scope(targetOrg))
+			stats.created++
+		case actionUpdate:
+			pterm.Success.Printf("Updated %s in %s\n", c.name, c.scope(targetOrg))
+			stats.updated++
+		case actionDelete:
+			pterm.Success.Printf("Deleted %s from %s\n", c.name, c.scope(targetOrg))
+			stats.deleted++
+		}
+	}
+
+	summary := output.NewSummary("apply", "Apply Summary")
+	summary.Add("", "Planned", "planned", fmt.Sprintf("%d to add, %d to change, %d to destroy", adds, updates, deletes))
+	summary.Add("✅", "Created", "created", stats.created)
+	summary.Add("🔄", "Updated", "updated", stats.updated)
+	summary.Add("🗑️ ", "Deleted", "deleted", stats.deleted)
+	summary.Add("❌", "Failed", "failed", stats.failed)
+	summary.Add("🕐", "Total time", "duration", time.Since(start).Round(time.Second).String())
+
+	if stats.failed > 0 {
+		summary.Print(output.StatusFailed)
+		output.Failed(fmt.Sprintf("apply completed with %d failed changes", stats.failed))
+		logging.Exit(1)
+	}
+
+	summary.Print(output.StatusSuccess)
+	output.Message("✅", "Apply completed successfully!")
+	return nil
+}
+
+// Makes a single planned change in the target
+func applyChange(c change, org, token, hostname string) error {
+	switch c.entity {
+	case api.EntityTypeOrg:
+		var err error
+		switch c.action {
+		case actionCreate:
+			err = api.AddOrgVariable(org, c.name, c.value, c.visibility, token, hostname)
+		case actionUpdate:
+			err = api.UpdateOrgVariable(org, c.name, c.value, c.visibility, token, hostname)
+		case actionDelete:
+			return api.DeleteOrgVariable(org, c.name, token, hostname)
+		}
+		if err != nil {
+			return err
+		}
+		// Selected repositories are managed separately from the variable itself
+		if c.visibility == visibilitySelected {
+			return api.SetSelectedReposForOrgVariable(org, c.name, c.selectedRepos, token, hostname)
+		}
+		return nil
+	case api.EntityTypeEnvironment:
+		switch c.action {
+		case actionCreate:
+			return api.AddEnvVariable(org, c.repo, c.env, c.name, c.value, token, hostname)
+		case actionUpdate:
+			return api.UpdateEnvVariable(org, c.repo, c.env, c.name, c.value, token, hostname)
+		case actionDelete:
+			return api.DeleteEnvVariable(org, c.repo, c.env, c.name, token, hostname)
+		}
+	default:
+		switch c.action {
+		case actionCreate:
+			return api.AddRepoVariable(org, c.repo, c.name, c.value, "", token, hostname)
+		case actionUpdate:
+			return api.UpdateRepoVariable(org, c.repo, c.name, c.value, "", token, hostname)
+		case actionDelete:
+			return api.DeleteRepoVariable(org, c.repo, c.name, token, hostname)
+		}
+	}
+	return fmt.Errorf("unknown action %q", c.action)
+}
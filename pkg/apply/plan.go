@@ -0,0 +1,234 @@
+package apply
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/pterm/pterm"
+)
+
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionDelete = "delete"
+)
+
+// change is a single difference between the desired state and the target
+type change struct {
+	action           string
+	entity           string
+	repo             string
+	env              string
+	name             string
+	value            string
+	oldValue         string
+	visibility       string
+	oldVisibility    string
+	selectedRepos    []string
+	oldSelectedRepos []string
+}
+
+// Returns the organization, repository, or environment heading a change is listed under
+func (c change) scope(org string) string {
+	switch c.entity {
+	case api.EntityTypeRepository:
+		return fmt.Sprintf("repository %s/%s", org, c.repo)
+	case api.EntityTypeEnvironment:
+		return fmt.Sprintf("environment %s/%s:%s", org, c.repo, c.env)
+	}
+	return "organization " + org
+}
+
+// Compares the desired state with the target and returns the changes needed to reconcile them.
+// With prune, undeclared variables in every declared scope are deleted.
+func buildPlan(state *desiredState, org, token, hostname string, prune bool) ([]change, error) {
+	var changes []change
+
+	// Organization-level variables are only managed when the file declares them
+	if state.Variables != nil {
+		current, err := api.FetchOrgVariables(org, token, hostname)
+		if err != nil {
+			return nil, err
+		}
+		existing := indexVariables(current)
+
+		for _, desired := range state.Variables {
+			c := change{
+				entity:        api.EntityTypeOrg,
+				name:          desired.Name,
+				value:         desired.Value,
+				visibility:    desired.Visibility,
+				selectedRepos: sortedCopy(desired.SelectedRepositories),
+			}
+
+			actual, ok := existing[desired.Name]
+			if !ok {
+				c.action = actionCreate
+				changes = append(changes, c)
+				continue
+			}
+
+			c.oldValue = actual["Value"]
+			c.oldVisibility = actual["Visibility"]
+			if c.oldVisibility == visibilitySelected {
+				selected, err := api.FetchSelectedReposForOrgVariable(org, desired.Name, token, hostname)
+				if err != nil {
+					return nil, err
+				}
+				c.oldSelectedRepos = sortedCopy(selected)
+			}
+			if c.value != c.oldValue || c.visibility != c.oldVisibility ||
+				(c.visibility == visibilitySelected && !slices.Equal(c.selectedRepos, c.oldSelectedRepos)) {
+				c.action = actionUpdate
+				changes = append(changes, c)
+			}
+		}
+
+		if prune {
+			changes = append(changes, pruneChanges(current, state.Variables, func(v orgVariable) string { return v.Name }, change{entity: api.EntityTypeOrg})...)
+		}
+	}
+
+	for _, repo := range state.Repositories {
+		current, err := api.FetchRepoVariables(org, repo.Name, token, hostname)
+		if err != nil {
+			if api.IsNotFound(err) {
+				return nil, fmt.Errorf("repository %s does not exist in organization %s", repo.Name, org)
+			}
+			return nil, err
+		}
+		changes = append(changes, diffVariables(current, repo.Variables, change{entity: api.EntityTypeRepository, repo: repo.Name}, prune)...)
+
+		for _, env := range repo.Environments {
+			current, err := api.FetchEnvVariables(org, repo.Name, env.Name, token, hostname)
+			// A missing environment is created when the plan is applied
+			if err != nil && !api.IsNotFound(err) {
+				return nil, err
+			}
+			changes = append(changes, diffVariables(current, env.Variables, change{entity: api.EntityTypeEnvironment, repo: repo.Name, env: env.Name}, prune)...)
+		}
+	}
+
+	return changes, nil
+}
+
+// Compares repository or environment variables with their desired values
+func diffVariables(current []map[string]string, desired []variable, base change, prune bool) []change {
+	var changes []change
+	existing := indexVariables(current)
+	for _, v := range desired {
+		c := base
+		c.name = v.Name
+		c.value = v.Value
+		actual, ok := existing[v.Name]
+		switch {
+		case !ok:
+			c.action = actionCreate
+		case actual["Value"] != v.Value:
+			c.action = actionUpdate
+			c.oldValue = actual["Value"]
+		default:
+			continue
+		}
+		changes = append(changes, c)
+	}
+
+	if prune {
+		changes = append(changes, pruneChanges(current, desired, func(v variable) string { return v.Name }, base)...)
+	}
+	return changes
+}
+
+// Builds delete changes for current variables that are not declared
+func pruneChanges[T any](current []map[string]string, desired []T, nameOf func(T) string, base change) []change {
+	declared := make(map[string]bool, len(desired))
+	for _, v := range desired {
+		declared[nameOf(v)] = true
+	}
+
+	var changes []change
+	for _, actual := range current {
+		if declared[actual["Name"]] {
+			continue
+		}
+		c := base
+		c.action = actionDelete
+		c.name = actual["Name"]
+		c.oldValue = actual["Value"]
+		c.oldVisibility = actual["Visibility"]
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// Prints the plan in a Terraform-like format and returns the add, change, and destroy counts
+func printPlan(changes []change, org string) (int, int, int) {
+	var adds, updates, deletes int
+	lastScope := ""
+
+	output.Message("📋", "Execution plan:")
+	for _, c := range changes {
+		if scope := c.scope(org); scope != lastScope {
+			fmt.Printf("\n  # %s\n", scope)
+			lastScope = scope
+		}
+
+		switch c.action {
+		case actionCreate:
+			adds++
+			fmt.Printf("  %s %s = %q%s\n", pterm.FgGreen.Sprint("+"), c.name, c.value, visibilitySuffix(c))
+		case actionUpdate:
+			updates++
+			fmt.Printf("  %s %s", pterm.FgYellow.Sprint("~"), c.name)
+			if c.value != c.oldValue {
+				fmt.Printf(" = %q -> %q", c.oldValue, c.value)
+			}
+			fmt.Println()
+			if c.entity == api.EntityTypeOrg && c.visibility != c.oldVisibility {
+				fmt.Printf("      visibility: %s -> %s\n", c.oldVisibility, c.visibility)
+			}
+			if c.visibility == visibilitySelected && !slices.Equal(c.selectedRepos, c.oldSelectedRepos) {
+				fmt.Printf("      selected_repositories: [%s] -> [%s]\n", strings.Join(c.oldSelectedRepos, ", "), strings.Join(c.selectedRepos, ", "))
+			}
+		case actionDelete:
+			deletes++
+			fmt.Printf("  %s %s\n", pterm.FgRed.Sprint("-"), c.name)
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("\n  No changes. The target matches the desired state.")
+	}
+	fmt.Printf("\nPlan: %d to add, %d to change, %d to destroy.\n", adds, updates, deletes)
+	return adds, updates, deletes
+}
+
+// Describes the visibility of an organization variable being created
+func visibilitySuffix(c change) string {
+	if c.entity != api.EntityTypeOrg {
+		return ""
+	}
+	if c.visibility == visibilitySelected {
+		return fmt.Sprintf(" (visibility: selected [%s])", strings.Join(c.selectedRepos, ", "))
+	}
+	return fmt.Sprintf(" (visibility: %s)", c.visibility)
+}
+
+// Indexes variables by name
+func indexVariables(variables []map[string]string) map[string]map[string]string {
+	index := make(map[string]map[string]string, len(variables))
+	for _, v := range variables {
+		index[v["Name"]] = v
+	}
+	return index
+}
+
+// Returns a sorted copy of a list of repository names
+func sortedCopy(values []string) []string {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted
+}
@@ -0,0 +1,141 @@
+package apply
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	visibilityAll      = "all"
+	visibilityPrivate  = "private"
+	visibilitySelected = "selected"
+)
+
+// desiredState is the declarative description of an organization's Actions variables
+type desiredState struct {
+	Organization string            `yaml:"organization"`
+	Variables    []orgVariable     `yaml:"variables"`
+	Repositories []repositoryState `yaml:"repositories"`
+}
+
+// orgVariable is an organization-level variable with its visibility and selected repositories
+type orgVariable struct {
+	Name                 string   `yaml:"name"`
+	Value                string   `yaml:"value"`
+	Visibility           string   `yaml:"visibility"`
+	SelectedRepositories []string `yaml:"selected_repositories"`
+}
+
+// variable is a repository- or environment-level variable
+type variable struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type repositoryState struct {
+	Name         string             `yaml:"name"`
+	Variables    []variable         `yaml:"variables"`
+	Environments []environmentState `yaml:"environments"`
+}
+
+type environmentState struct {
+	Name      string     `yaml:"name"`
+	Variables []variable `yaml:"variables"`
+}
+
+// Reads and validates a desired state file
+func loadDesiredState(path string) (*desiredState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file %s: %w", path, err)
+	}
+
+	var state desiredState
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&state); err != nil {
+		return nil, fmt.Errorf("cannot parse file %s: %w", path, err)
+	}
+
+	if err := state.validate(); err != nil {
+		return nil, fmt.Errorf("invalid desired state in %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// Checks for missing names, duplicates, and inconsistent visibility settings
+func (s *desiredState) validate() error {
+	seen := make(map[string]bool)
+	for i := range s.Variables {
+		v := &s.Variables[i]
+		if v.Name == "" {
+			return fmt.Errorf("organization variable #%d has no name", i+1)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("organization variable %s is declared more than once", v.Name)
+		}
+		seen[v.Name] = true
+
+		if v.Visibility == "" {
+			v.Visibility = visibilityPrivate
+		}
+		switch v.Visibility {
+		case visibilityAll, visibilityPrivate:
+			if len(v.SelectedRepositories) > 0 {
+				return fmt.Errorf("organization variable %s lists selected_repositories but its visibility is %s", v.Name, v.Visibility)
+			}
+		case visibilitySelected:
+		default:
+			return fmt.Errorf("organization variable %s has unknown visibility %q (expected all, private, or selected)", v.Name, v.Visibility)
+		}
+	}
+
+	seenRepos := make(map[string]bool)
+	for i, repo := range s.Repositories {
+		if repo.Name == "" {
+			return fmt.Errorf("repository #%d has no name", i+1)
+		}
+		if seenRepos[repo.Name] {
+			return fmt.Errorf("repository %s is declared more than once", repo.Name)
+		}
+		seenRepos[repo.Name] = true
+
+		if err := validateVariables(repo.Variables, repo.Name); err != nil {
+			return err
+		}
+
+		seenEnvs := make(map[string]bool)
+		for j, env := range repo.Environments {
+			if env.Name == "" {
+				return fmt.Errorf("environment #%d in repository %s has no name", j+1, repo.Name)
+			}
+			if seenEnvs[env.Name] {
+				return fmt.Errorf("environment %s in repository %s is declared more than once", env.Name, repo.Name)
+			}
+			seenEnvs[env.Name] = true
+
+			if err := validateVariables(env.Variables, repo.Name+"/"+env.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Checks a list of repository or environment variables for missing and duplicate names
+func validateVariables(variables []variable, scope string) error {
+	seen := make(map[string]bool)
+	for i, v := range variables {
+		if v.Name == "" {
+			return fmt.Errorf("variable #%d in %s has no name", i+1, scope)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("variable %s in %s is declared more than once", v.Name, scope)
+		}
+		seen[v.Name] = true
+	}
+	return nil
+}
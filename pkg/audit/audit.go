@@ -0,0 +1,348 @@
+package audit
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// Kinds of audit findings
+const (
+	FindingUnused   = "unused"
+	FindingMissing  = "missing"
+	FindingShadowed = "shadowed"
+)
+
+// Finding is a single result of the audit
+type Finding struct {
+	Kind     string
+	Variable string
+	// Scope is "organization" or the repository the variable is defined in
+	Scope      string
+	Repository string
+	Workflows  []string
+	Detail     string
+}
+
+// variables holds the exported variables of one organization, keyed by upper-cased name
+type variables struct {
+	org  map[string]orgVariable
+	repo map[string]map[string]bool
+}
+
+// orgVariable is the access policy of an organization variable
+type orgVariable struct {
+	visibility string
+	// selected holds the lower-cased names of the selected repositories
+	selected map[string]bool
+}
+
+// Reports whether an organization variable is available to a repository. Repositories whose
+// visibility is unknown, such as local clones, count as private.
+func (v orgVariable) visibleTo(repo, repoVisibility string) bool {
+	switch v.visibility {
+	case "private":
+		return repoVisibility != "public"
+	case "selected":
+		return v.selected[strings.ToLower(repo)]
+	}
+	return true
+}
+
+// RunAudit scans the workflows of every repository in an organization for vars.NAME references
+// and compares them with the exported variables, reporting unused variables, references to
+// variables that do not exist, and organization variables shadowed by repository variables
+func RunAudit() error {
+	start := time.Now()
+
+	org := viper.GetString("source-organization")
+	token := viper.GetString("source-token")
+	hostname := viper.GetString("source-hostname")
+	variablesFile := viper.GetString("file")
+	workflowsDir := viper.GetString("workflows-dir")
+	reportFile := viper.GetString("report-file")
+	if reportFile == "" {
+		reportFile = org + "_variable_audit.csv"
+	}
+
+	if org == "" || variablesFile == "" {
+		return fmt.Errorf("missing required parameters: source organization and variables file")
+	}
+	if workflowsDir == "" && token == "" {
+		return fmt.Errorf("missing required parameters: source token, or a workflows directory with local clones")
+	}
+
+	defined, err := readVariables(variablesFile, org)
+	if err != nil {
+		return err
+	}
+
+	var repos []string
+	// Repository visibility decides which organization variables a repository can read
+	repoVisibility := make(map[string]string)
+	if workflowsDir != "" {
+		repos, err = listLocalRepositories(workflowsDir)
+		pterm.Info.Printf("Scanning workflows of %d repositories cloned into %s\n", len(repos), workflowsDir)
+	} else {
+		var catalog *api.RepoCatalog
+		catalog, err = api.FetchRepoCatalog(org, token, hostname)
+		if catalog != nil {
+			repos = catalog.Names()
+			for _, repo := range catalog.Repositories {
+				repoVisibility[repo.Name] = repo.Visibility
+			}
+		}
+		pterm.Info.Printf("Scanning workflows of %d repositories in %s\n", len(repos), org)
+	}
+	if err != nil {
+		return err
+	}
+
+	output.Group("Scanning workflows")
+	progress := output.StartProgress("Scanning workflows", len(repos))
+	scanned := make(map[string]references)
+	var failed, workflowFiles int
+	for _, repo := range repos {
+		var files []api.WorkflowFile
+		if workflowsDir != "" {
+			files, err = readLocalWorkflowFiles(workflowsDir, repo)
+		} else {
+			files, err = api.FetchWorkflowFiles(org, repo, token, hostname)
+		}
+		if err != nil {
+			output.Error("Failed to scan workflows of %s: %v", repo, err)
+			failed++
+			progress.Failed()
+			continue
+		}
+
+		refs := make(references)
+		for _, file := range files {
+			refs.scan(file)
+		}
+		scanned[repo] = refs
+		workflowFiles += len(files)
+		output.Detail(pterm.Info, "Found %d workflows referencing %d variables in %s\n", len(files), len(refs), repo)
+		progress.Succeeded()
+	}
+	progress.Stop()
+
+	findings := crossReference(defined, scanned, repoVisibility)
+	if err := writeReport(reportFile, findings); err != nil {
+		return err
+	}
+	printFindings(findings)
+
+	counts := make(map[string]int)
+	for _, finding := range findings {
+		counts[finding.Kind]++
+		output.Event("finding", map[string]any{
+			"kind":       finding.Kind,
+			"variable":   finding.Variable,
+			"scope":      finding.Scope,
+			"repository": finding.Repository,
+			"workflows":  finding.Workflows,
+		})
+	}
+
+	summary := output.NewSummary("audit", "Audit Summary")
+	summary.Add("", "Repositories scanned", "repositories", len(scanned))
+	summary.Add("", "Workflow files", "workflows", workflowFiles)
+	summary.Add("❌", "Failed to scan", "failed", failed)
+	summary.Add("💤", "Unused variables", "unused", counts[FindingUnused])
+	summary.Add("❓", "References to missing variables", "missing", counts[FindingMissing])
+	summary.Add("🙈", "Shadowed organization variables", "shadowed", counts[FindingShadowed])
+	summary.Add("📁", "Report file", "report_file", reportFile)
+	summary.Add("🕐", "Total time", "duration", time.Since(start).Round(time.Second).String())
+
+	if failed > 0 {
+		summary.Print(output.StatusFailed)
+		output.Failed(fmt.Sprintf("audit could not scan %d repositories, their variables are not included", failed))
+		logging.Exit(1)
+	}
+
+	summary.Print(output.StatusSuccess)
+	output.Message("✅", "Audit completed successfully!")
+	return nil
+}
+
+// Reads the organization and repository variables of an organization from an export CSV.
+// Rows of other organizations are ignored; exports without an organization column are
+// assumed to belong to the audited organization.
+func readVariables(file, org string) (*variables, error) {
+	handle, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open file %s: %w", file, err)
+	}
+	defer handle.Close()
+
+	reader := csv.NewReader(handle)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file %s: %w", file, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("file %s is empty", file)
+	}
+
+	defined := &variables{org: make(map[string]orgVariable), repo: make(map[string]map[string]bool)}
+	// Skip header row
+	for _, row := range rows[1:] {
+		if len(row) < 3 || row[0] == "" {
+			continue
+		}
+		if len(row) > 4 && row[4] != "" && !strings.EqualFold(row[4], org) {
+			continue
+		}
+		name, repo := strings.ToUpper(row[0]), row[2]
+		if repo == api.EntityTypeOrg {
+			variable := orgVariable{selected: make(map[string]bool)}
+			if len(row) > 3 {
+				variable.visibility = strings.ToLower(row[3])
+			}
+			if len(row) > 5 && row[5] != "" {
+				for _, selected := range strings.Split(row[5], api.SelectedReposSeparator) {
+					variable.selected[strings.ToLower(selected)] = true
+				}
+			}
+			defined.org[name] = variable
+			continue
+		}
+		if defined.repo[repo] == nil {
+			defined.repo[repo] = make(map[string]bool)
+		}
+		defined.repo[repo][name] = true
+	}
+	return defined, nil
+}
+
+// Compares the defined variables with the references found in each scanned repository. References
+// to organization variables that are not available to the repository count as missing.
+func crossReference(defined *variables, scanned map[string]references, repoVisibility map[string]string) []Finding {
+	var findings []Finding
+	orgUsage := make(map[string][]string)
+
+	for _, repo := range sortedKeys(scanned) {
+		refs := scanned[repo]
+		for _, name := range refs.names() {
+			orgVar, isOrg := defined.org[name]
+			switch {
+			case defined.repo[repo][name]:
+			case isOrg && orgVar.visibleTo(repo, repoVisibility[repo]):
+				orgUsage[name] = append(orgUsage[name], refs[name]...)
+			case isOrg:
+				findings = append(findings, Finding{
+					Kind:       FindingMissing,
+					Variable:   name,
+					Repository: repo,
+					Workflows:  refs[name],
+					Detail:     fmt.Sprintf("the organization variable is not available to the repository (visibility %s)", orgVar.visibility),
+				})
+			default:
+				findings = append(findings, Finding{
+					Kind:       FindingMissing,
+					Variable:   name,
+					Repository: repo,
+					Workflows:  refs[name],
+					Detail:     "referenced but not defined in the repository or organization",
+				})
+			}
+		}
+		for _, name := range sortedKeys(defined.repo[repo]) {
+			if _, ok := refs[name]; !ok {
+				findings = append(findings, Finding{
+					Kind:       FindingUnused,
+					Variable:   name,
+					Scope:      repo,
+					Repository: repo,
+					Detail:     "not referenced in the repository's workflows",
+				})
+			}
+		}
+	}
+
+	// An organization variable is used when a repository references it without overriding it
+	for _, name := range sortedKeys(defined.org) {
+		if len(orgUsage[name]) == 0 {
+			findings = append(findings, Finding{
+				Kind:     FindingUnused,
+				Variable: name,
+				Scope:    api.EntityTypeOrg,
+				Detail:   "not referenced in any scanned workflow, or only where a repository variable overrides it",
+			})
+		}
+	}
+
+	for _, repo := range sortedKeys(defined.repo) {
+		for _, name := range sortedKeys(defined.repo[repo]) {
+			if orgVar, ok := defined.org[name]; ok && orgVar.visibleTo(repo, repoVisibility[repo]) {
+				findings = append(findings, Finding{
+					Kind:       FindingShadowed,
+					Variable:   name,
+					Scope:      api.EntityTypeOrg,
+					Repository: repo,
+					Workflows:  scanned[repo][name],
+					Detail:     "the repository variable of the same name takes precedence",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// Writes the findings to a CSV report
+func writeReport(file string, findings []Finding) error {
+	handle, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("cannot create file %s: %w", file, err)
+	}
+	defer handle.Close()
+
+	writer := csv.NewWriter(handle)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Finding", "Variable", "Scope", "Repository", "Workflows", "Detail"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, finding := range findings {
+		row := []string{finding.Kind, finding.Variable, finding.Scope, finding.Repository, strings.Join(finding.Workflows, ";"), finding.Detail}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write finding to CSV: %w", err)
+		}
+	}
+	return nil
+}
+
+// Prints the findings as a table
+func printFindings(findings []Finding) {
+	if len(findings) == 0 {
+		pterm.Success.Println("No unused, missing, or shadowed variables found")
+		return
+	}
+	data := pterm.TableData{{"Finding", "Variable", "Scope", "Repository", "Workflows"}}
+	for _, finding := range findings {
+		data = append(data, []string{finding.Kind, finding.Variable, finding.Scope, finding.Repository, strings.Join(finding.Workflows, ", ")})
+	}
+	fmt.Println()
+	pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
+
+// Returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
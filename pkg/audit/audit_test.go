@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadVariables(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "variables.csv")
+	content := "Name,Value,Scope,Visibility,Organization,SelectedRepositories\n" +
+		"env,prod,organization,all,org,\n" +
+		"token,x,organization,selected,org,Frontend;backend\n" +
+		"url,x,frontend,,org,\n" +
+		"other,x,organization,all,elsewhere,\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	defined, err := readVariables(file, "org")
+	if err != nil {
+		t.Fatalf("readVariables: %v", err)
+	}
+	want := &variables{
+		org: map[string]orgVariable{
+			"ENV":   {visibility: "all", selected: map[string]bool{}},
+			"TOKEN": {visibility: "selected", selected: map[string]bool{"frontend": true, "backend": true}},
+		},
+		repo: map[string]map[string]bool{"frontend": {"URL": true}},
+	}
+	if !reflect.DeepEqual(defined, want) {
+		t.Fatalf("got %+v, want %+v", defined, want)
+	}
+}
+
+func TestCrossReference(t *testing.T) {
+	workflows := []string{"ci.yml"}
+	tests := []struct {
+		name           string
+		variable       orgVariable
+		repoVisibility string
+		repoDefines    bool
+		want           []string
+	}{
+		{name: "visible to all", variable: orgVariable{visibility: "all"}, repoVisibility: "public"},
+		{name: "private from private repository", variable: orgVariable{visibility: "private"}, repoVisibility: "private"},
+		{name: "private from repository of unknown visibility", variable: orgVariable{visibility: "private"}},
+		{
+			name:           "private from public repository",
+			variable:       orgVariable{visibility: "private"},
+			repoVisibility: "public",
+			want:           []string{FindingMissing, FindingUnused},
+		},
+		{
+			name:     "selected repository",
+			variable: orgVariable{visibility: "selected", selected: map[string]bool{"app": true}},
+		},
+		{
+			name:     "repository not selected",
+			variable: orgVariable{visibility: "selected", selected: map[string]bool{"other": true}},
+			want:     []string{FindingMissing, FindingUnused},
+		},
+		{
+			name:        "shadowed where visible",
+			variable:    orgVariable{visibility: "all"},
+			repoDefines: true,
+			want:        []string{FindingUnused, FindingShadowed},
+		},
+		{
+			name:        "not shadowed where not selected",
+			variable:    orgVariable{visibility: "selected", selected: map[string]bool{}},
+			repoDefines: true,
+			want:        []string{FindingUnused},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defined := &variables{
+				org:  map[string]orgVariable{"ENV": tt.variable},
+				repo: map[string]map[string]bool{},
+			}
+			if tt.repoDefines {
+				defined.repo["app"] = map[string]bool{"ENV": true}
+			}
+			scanned := map[string]references{"app": {"ENV": workflows}}
+
+			var got []string
+			for _, finding := range crossReference(defined, scanned, map[string]string{"app": tt.repoVisibility}) {
+				got = append(got, finding.Kind)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got findings %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
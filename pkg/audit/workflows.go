@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+)
+
+// Matches vars.NAME and vars['NAME'] in workflow expressions
+var (
+	dotReference     = regexp.MustCompile(`\bvars\.([A-Za-z_][A-Za-z0-9_]*)`)
+	bracketReference = regexp.MustCompile(`\bvars\[\s*['"]([A-Za-z_][A-Za-z0-9_]*)['"]\s*\]`)
+)
+
+// references maps each referenced variable name to the workflow files that reference it
+type references map[string][]string
+
+// Adds the variables referenced in a workflow file. Names are upper-cased because variable names
+// and expressions are case-insensitive. Comment lines are ignored.
+func (r references) scan(file api.WorkflowFile) {
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(file.Content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, pattern := range []*regexp.Regexp{dotReference, bracketReference} {
+			for _, match := range pattern.FindAllStringSubmatch(line, -1) {
+				name := strings.ToUpper(match[1])
+				if !seen[name] {
+					seen[name] = true
+					r[name] = append(r[name], file.Path)
+				}
+			}
+		}
+	}
+}
+
+// Returns the referenced names in order
+func (r references) names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lists the repositories of a local clone directory, one subdirectory per repository
+func listLocalRepositories(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read workflows directory %s: %w", dir, err)
+	}
+	var repos []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			repos = append(repos, entry.Name())
+		}
+	}
+	return repos, nil
+}
+
+// Reads the workflow files of a repository cloned into dir/<repo>
+func readLocalWorkflowFiles(dir, repo string) ([]api.WorkflowFile, error) {
+	workflowsDir := filepath.Join(dir, repo, filepath.FromSlash(api.WorkflowsPath))
+	entries, err := os.ReadDir(workflowsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", workflowsDir, err)
+	}
+
+	var files []api.WorkflowFile
+	for _, entry := range entries {
+		if entry.IsDir() || !api.IsWorkflowFile(entry.Name()) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(workflowsDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read workflow %s of %s: %w", entry.Name(), repo, err)
+		}
+		files = append(files, api.WorkflowFile{Path: api.WorkflowsPath + "/" + entry.Name(), Content: string(content)})
+	}
+	return files, nil
+}
@@ -0,0 +1,88 @@
+package doctor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/mona-actions/gh-migrate-variables/pkg/preflight"
+	"github.com/spf13/viper"
+)
+
+// RunDoctor runs the access checks of the source and target tokens on their own, so that missing
+// scopes or permissions can be fixed before a migration is attempted
+func RunDoctor() error {
+	start := time.Now()
+
+	var endpoints []preflight.Endpoint
+	if token := viper.GetString("source-token"); token != "" {
+		endpoints = append(endpoints, preflight.Endpoint{
+			Label:         "Source",
+			Token:         token,
+			Hostname:      viper.GetString("source-hostname"),
+			Organizations: splitList(viper.GetString("source-organization")),
+			SampleRepo:    viper.GetString("source-sample-repo"),
+			Access:        preflight.AccessRead,
+		})
+	}
+	if token := viper.GetString("target-token"); token != "" {
+		endpoints = append(endpoints, preflight.Endpoint{
+			Label:         "Target",
+			Token:         token,
+			Hostname:      viper.GetString("target-hostname"),
+			Organizations: splitList(viper.GetString("target-organization")),
+			SampleRepo:    viper.GetString("target-sample-repo"),
+			Access:        preflight.AccessWrite,
+			OrgWrite:      true,
+			WriteTest:     !viper.GetBool("GHMV_SKIP_WRITE_TEST"),
+		})
+	}
+	if len(endpoints) == 0 {
+		return fmt.Errorf("missing required parameters: source token or target token")
+	}
+
+	var passed, warned, failed int
+	for _, endpoint := range endpoints {
+		results := preflight.CheckEndpoint(endpoint)
+		preflight.PrintChecklist(endpoint.Label, results)
+		for _, result := range results {
+			switch result.Status {
+			case preflight.StatusPass:
+				passed++
+			case preflight.StatusWarn:
+				warned++
+			case preflight.StatusFail:
+				failed++
+			}
+		}
+	}
+
+	summary := output.NewSummary("doctor", "Doctor Summary")
+	summary.Add("✅", "Passed", "passed", passed)
+	summary.Add("⚠️ ", "Warnings", "warnings", warned)
+	summary.Add("❌", "Failed", "failed", failed)
+	summary.Add("🕐", "Total time", "duration", time.Since(start).Round(time.Second).String())
+
+	if failed > 0 {
+		summary.Print(output.StatusFailed)
+		output.Failed(fmt.Sprintf("doctor found %d failed checks", failed))
+		logging.Exit(1)
+	}
+
+	summary.Print(output.StatusSuccess)
+	output.Message("✅", "All access checks passed!")
+	return nil
+}
+
+// Splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,231 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/pterm/pterm"
+)
+
+// Scopes a variable can be defined in, from the highest precedence to the lowest
+const (
+	sourceEnvironment = "environment"
+	sourceRepository  = "repository"
+	sourceOrg         = "organization"
+)
+
+// repoScope holds the variables that apply to one repository besides organization variables
+type repoScope struct {
+	name       string
+	visibility string
+	variables  []map[string]string
+	// environments maps each deployment environment to its variables
+	environments map[string][]map[string]string
+}
+
+// definition is a variable defined in one scope
+type definition struct {
+	source string
+	value  string
+}
+
+// effectiveValue is the value a workflow sees for a variable in a repository, or in a job that
+// uses one of its environments
+type effectiveValue struct {
+	organization string
+	repository   string
+	environment  string
+	name         string
+	value        string
+	source       string
+	// overridden lists the lower-precedence definitions the effective value hides
+	overridden []definition
+	// conflict is set when an overridden definition has a different value
+	conflict bool
+}
+
+// Collects the repository's environments and their variables for the effective value report
+func collectRepoScope(organization, repo string, repoVariables []map[string]string, catalog *api.RepoCatalog, token, hostname string) (*repoScope, error) {
+	scope := &repoScope{name: repo, variables: repoVariables, environments: make(map[string][]map[string]string)}
+	if info, ok := catalog.Lookup(repo); ok {
+		scope.visibility = info.Visibility
+	}
+
+	environments, err := api.FetchEnvironments(organization, repo, token, hostname)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range environments {
+		envVariables, err := api.FetchEnvVariables(organization, repo, env, token, hostname)
+		if err != nil {
+			return nil, err
+		}
+		scope.environments[env] = envVariables
+	}
+	return scope, nil
+}
+
+// Reports whether an organization variable is available to a repository. Private visibility
+// covers private and internal repositories, selected visibility only the selected ones.
+func orgVariableVisible(variable map[string]string, scope *repoScope, selected map[string]map[string]bool) bool {
+	switch variable["Visibility"] {
+	case "private":
+		return scope.visibility != "public"
+	case "selected":
+		return selected[variable["Name"]][strings.ToLower(scope.name)]
+	}
+	return true
+}
+
+// Computes the effective value of every variable in every repository with GitHub's precedence:
+// environment variables override repository variables, which override organization variables.
+// Each repository gets a row per variable for jobs without an environment, and each environment a
+// row per variable it defines itself.
+func computeEffectiveValues(result *orgResult) []effectiveValue {
+	var orgVariables []map[string]string
+	for _, variable := range result.variables {
+		if variable["Scope"] == api.EntityTypeOrg {
+			orgVariables = append(orgVariables, variable)
+		}
+	}
+
+	var values []effectiveValue
+	for _, scope := range result.scopes {
+		// Variable names are case-insensitive, so definitions are matched upper-cased
+		definitions := make(map[string][]definition)
+		for _, variable := range scope.variables {
+			name := strings.ToUpper(variable["Name"])
+			definitions[name] = append(definitions[name], definition{sourceRepository, variable["Value"]})
+		}
+		for _, variable := range orgVariables {
+			if orgVariableVisible(variable, scope, result.selectedRepos) {
+				name := strings.ToUpper(variable["Name"])
+				definitions[name] = append(definitions[name], definition{sourceOrg, variable["Value"]})
+			}
+		}
+		for _, name := range sortedNames(definitions) {
+			values = append(values, resolve(result.organization, scope.name, "", name, definitions[name]))
+		}
+
+		for _, env := range sortedNames(scope.environments) {
+			for _, variable := range scope.environments[env] {
+				name := strings.ToUpper(variable["Name"])
+				chain := append([]definition{{sourceEnvironment, variable["Value"]}}, definitions[name]...)
+				values = append(values, resolve(result.organization, scope.name, env, name, chain))
+			}
+		}
+	}
+	return values
+}
+
+// Picks the highest-precedence definition of a variable and flags differing overridden values
+func resolve(organization, repo, env, name string, chain []definition) effectiveValue {
+	value := effectiveValue{
+		organization: organization,
+		repository:   repo,
+		environment:  env,
+		name:         name,
+		value:        chain[0].value,
+		source:       chain[0].source,
+		overridden:   chain[1:],
+	}
+	for _, overridden := range value.overridden {
+		if overridden.value != value.value {
+			value.conflict = true
+		}
+	}
+	return value
+}
+
+// Reports every effective value that hides a different value from a lower scope and returns
+// their number. Events carry the scopes involved but not the values.
+func reportConflicts(values []effectiveValue) int {
+	conflicts := 0
+	for _, value := range values {
+		if !value.conflict {
+			continue
+		}
+		conflicts++
+		var sources []string
+		for _, overridden := range value.overridden {
+			sources = append(sources, overridden.source)
+		}
+		location := value.organization + "/" + value.repository
+		if value.environment != "" {
+			location += " (environment " + value.environment + ")"
+		}
+		output.Detail(pterm.Warning, "Conflict: %s in %s comes from the %s scope and overrides different values in %s\n",
+			value.name, location, value.source, strings.Join(sources, ", "))
+		output.Event("conflict", map[string]any{
+			"organization": value.organization,
+			"repository":   value.repository,
+			"environment":  value.environment,
+			"name":         value.name,
+			"source":       value.source,
+			"overrides":    sources,
+		})
+	}
+	return conflicts
+}
+
+// Returns the keys of a map in order
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Builds the effective values report file name next to a variables CSV file
+func effectiveValuesOutputFile(variablesFile string) string {
+	if strings.HasSuffix(variablesFile, "_variables.csv") {
+		return strings.TrimSuffix(variablesFile, "_variables.csv") + "_effective_values.csv"
+	}
+	return strings.TrimSuffix(variablesFile, filepath.Ext(variablesFile)) + "_effective_values.csv"
+}
+
+// Writes effective values to a CSV file
+func writeEffectiveValuesCSV(outputFile string, values []effectiveValue) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("cannot create file %s: %w", outputFile, err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	// Write header
+	if err := writer.Write([]string{"Organization", "Repository", "Environment", "Name", "Value", "Source", "Overrides", "Conflict"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, value := range values {
+		var overrides []string
+		for _, overridden := range value.overridden {
+			overrides = append(overrides, fmt.Sprintf("%s=%s", overridden.source, overridden.value))
+		}
+		row := []string{
+			value.organization,
+			value.repository,
+			value.environment,
+			value.name,
+			value.value,
+			value.source,
+			strings.Join(overrides, ";"),
+			fmt.Sprintf("%t", value.conflict),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write effective value to CSV: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package export
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name         string
+		chain        []definition
+		wantSource   string
+		wantValue    string
+		wantConflict bool
+	}{
+		{name: "single definition", chain: []definition{{sourceOrg, "a"}}, wantSource: sourceOrg, wantValue: "a"},
+		{
+			name:       "same value in a lower scope",
+			chain:      []definition{{sourceRepository, "a"}, {sourceOrg, "a"}},
+			wantSource: sourceRepository,
+			wantValue:  "a",
+		},
+		{
+			name:         "different value in a lower scope",
+			chain:        []definition{{sourceEnvironment, "b"}, {sourceRepository, "a"}, {sourceOrg, "a"}},
+			wantSource:   sourceEnvironment,
+			wantValue:    "b",
+			wantConflict: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := resolve("org", "app", "", "NAME", tt.chain)
+			if value.source != tt.wantSource || value.value != tt.wantValue || value.conflict != tt.wantConflict {
+				t.Fatalf("got %s=%q conflict %v, want %s=%q conflict %v",
+					value.source, value.value, value.conflict, tt.wantSource, tt.wantValue, tt.wantConflict)
+			}
+			if !reflect.DeepEqual(value.overridden, tt.chain[1:]) {
+				t.Fatalf("overridden %v, want %v", value.overridden, tt.chain[1:])
+			}
+		})
+	}
+}
+
+func TestComputeEffectiveValues(t *testing.T) {
+	orgVariable := func(name, value, visibility string) map[string]string {
+		return map[string]string{"Name": name, "Value": value, "Scope": api.EntityTypeOrg, "Visibility": visibility}
+	}
+	repoVariable := func(name, value string) map[string]string {
+		return map[string]string{"Name": name, "Value": value}
+	}
+	// Each effective value as repository, environment, name, source, value, and conflict
+	type row struct {
+		repo, env, name, source, value string
+		conflict                       bool
+	}
+
+	tests := []struct {
+		name          string
+		orgVariables  []map[string]string
+		selectedRepos map[string]map[string]bool
+		scope         *repoScope
+		want          []row
+	}{
+		{
+			name:         "organization variable visible to all",
+			orgVariables: []map[string]string{orgVariable("ENV", "prod", "all")},
+			scope:        &repoScope{name: "app", visibility: "public"},
+			want:         []row{{"app", "", "ENV", sourceOrg, "prod", false}},
+		},
+		{
+			name:         "private organization variable skips public repositories",
+			orgVariables: []map[string]string{orgVariable("ENV", "prod", "private")},
+			scope:        &repoScope{name: "app", visibility: "public"},
+		},
+		{
+			name:          "selected organization variable",
+			orgVariables:  []map[string]string{orgVariable("ENV", "prod", "selected"), orgVariable("URL", "x", "selected")},
+			selectedRepos: map[string]map[string]bool{"ENV": {"app": true}, "URL": {"other": true}},
+			scope:         &repoScope{name: "App", visibility: "private"},
+			want:          []row{{"App", "", "ENV", sourceOrg, "prod", false}},
+		},
+		{
+			name:         "repository variable overrides case-insensitively",
+			orgVariables: []map[string]string{orgVariable("env", "prod", "all")},
+			scope:        &repoScope{name: "app", variables: []map[string]string{repoVariable("ENV", "staging")}},
+			want:         []row{{"app", "", "ENV", sourceRepository, "staging", true}},
+		},
+		{
+			name:         "environment variable overrides the repository and organization",
+			orgVariables: []map[string]string{orgVariable("ENV", "prod", "all")},
+			scope: &repoScope{
+				name:         "app",
+				variables:    []map[string]string{repoVariable("ENV", "prod")},
+				environments: map[string][]map[string]string{"staging": {repoVariable("ENV", "staging")}},
+			},
+			want: []row{
+				{"app", "", "ENV", sourceRepository, "prod", false},
+				{"app", "staging", "ENV", sourceEnvironment, "staging", true},
+			},
+		},
+		{
+			name:         "environment variable without lower definitions",
+			orgVariables: nil,
+			scope: &repoScope{
+				name:         "app",
+				environments: map[string][]map[string]string{"staging": {repoVariable("DEBUG", "1")}},
+			},
+			want: []row{{"app", "staging", "DEBUG", sourceEnvironment, "1", false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &orgResult{
+				organization:  "org",
+				variables:     append(tt.orgVariables, map[string]string{"Name": "LOCAL", "Scope": "other"}),
+				scopes:        []*repoScope{tt.scope},
+				selectedRepos: tt.selectedRepos,
+			}
+			var got []row
+			for _, value := range computeEffectiveValues(result) {
+				got = append(got, row{value.repository, value.environment, value.name, value.source, value.value, value.conflict})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,76 +1,607 @@
 package export
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/mona-actions/gh-migrate-variables/pkg/preflight"
+	"github.com/mona-actions/gh-migrate-variables/pkg/secretscan"
+	"github.com/mona-actions/gh-migrate-variables/pkg/wizard"
 	"github.com/pterm/pterm"
 	"github.com/spf13/viper"
 )
 
+// orgResult holds the outcome of exporting a single organization
+type orgResult struct {
+	organization string
+	repositories int
+	successful   int
+	failed       int
+	variables    []map[string]string
+	secrets      []map[string]string
+	// deniedStores are the secret stores the token cannot read, which are skipped for the organization
+	deniedStores []string
+	outputFile   string
+	err          error
+	// orgErr is set when the organization variables or their selected repositories could not be
+	// fetched. The repositories are still exported, but the organization counts as failed.
+	orgErr error
+
+	// Inputs and results of the effective value report
+	scopes        []*repoScope
+	selectedRepos map[string]map[string]bool
+	effective     []effectiveValue
+}
+
 func ExportVariables() error {
 	start := time.Now()
-	spinner, _ := pterm.DefaultSpinner.Start("Exporting variables...")
 	// Validate environment variables
-	organization := viper.GetString("source-organization")
 	token := viper.GetString("source-token")
 	hostname := viper.GetString("source-hostname")
 
-	if organization == "" || token == "" {
-		return fmt.Errorf("missing required environment variables: GHMV_SOURCE_ORGANIZATION, GHMV_SOURCE_TOKEN, or VARIABLES_CSV_FILE")
+	if token == "" {
+		return fmt.Errorf("missing required environment variables: GHMV_SOURCE_TOKEN")
+	}
+	if err := wizard.Check(); err != nil {
+		return err
+	}
+	scanner, err := secretscan.New(viper.GetString("secret-scan-policy"), strings.Split(viper.GetString("secret-scan-ignore"), ","))
+	if err != nil {
+		return err
+	}
+
+	includeSecrets := viper.GetBool("GHMV_INCLUDE_SECRETS")
+	effectiveValues := viper.GetBool("GHMV_EFFECTIVE_VALUES")
+	var secretStores []string
+	var optionalFeatures []string
+	if includeSecrets {
+		secretStores, err = resolveSecretStores(viper.GetString("secret-stores"))
+		if err != nil {
+			return err
+		}
+		for _, store := range secretStores {
+			optionalFeatures = append(optionalFeatures, api.SecretStoreFeature(store))
+		}
+	}
+
+	// Check that the source instance supports what the export needs before any work starts
+	capabilities, err := preflight.Check(token, hostname, []string{api.FeatureActionsVariables}, optionalFeatures)
+	if err != nil {
+		return err
+	}
+	supportedStores := secretStores[:0]
+	for _, store := range secretStores {
+		if capabilities.Supports(api.SecretStoreFeature(store)) {
+			supportedStores = append(supportedStores, store)
+		}
+	}
+	secretStores = supportedStores
+
+	organizations, err := resolveOrganizations(token, hostname)
+	if err != nil {
+		return err
+	}
+	if len(organizations) == 0 && wizard.Enabled() {
+		organizations, err = selectOrganizations(token, hostname)
+		if err != nil {
+			return err
+		}
+	}
+	if len(organizations) == 0 {
+		return fmt.Errorf("missing required environment variables: GHMV_SOURCE_ORGANIZATION, GHMV_ORGANIZATIONS_FILE, or GHMV_ENTERPRISE")
+	}
+
+	// Check that the token can read every organization before exporting anything
+	if err := preflight.CheckAccess(preflight.Endpoint{
+		Label:         "Source",
+		Token:         token,
+		Hostname:      hostname,
+		Organizations: organizations,
+		Access:        preflight.AccessRead,
+	}); err != nil {
+		return err
+	}
+
+	// In interactive mode the operator picks the repositories to export from each organization
+	selectedRepos := make(map[string][]string)
+	if wizard.Enabled() {
+		for _, organization := range organizations {
+			catalog, err := api.FetchRepoCatalog(organization, token, hostname)
+			if err != nil {
+				return fmt.Errorf("failed to fetch repositories for %s: %w", organization, err)
+			}
+			selectedRepos[organization], err = wizard.SelectMany(fmt.Sprintf("Repositories to export from %s", organization), catalog.Names())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	output.Group("Exporting variables")
+
+	var results []*orgResult
+	for _, organization := range organizations {
+		result := exportOrganization(organization, token, hostname, secretStores, selectedRepos[organization], effectiveValues)
+		event := map[string]any{
+			"organization": organization,
+			"repositories": result.repositories,
+			"processed":    result.successful,
+			"failed":       result.failed,
+			"variables":    len(result.variables),
+			"secrets":      len(result.secrets),
+		}
+		if result.err != nil {
+			event["error"] = result.err.Error()
+		} else if result.orgErr != nil {
+			event["error"] = result.orgErr.Error()
+		}
+		output.Event("organization", event)
+		// A single organization export keeps failing fast when its repositories cannot be listed
+		if result.err != nil && len(organizations) == 1 {
+			return result.err
+		}
+		results = append(results, result)
+	}
+
+	// Check every value for credentials before anything is written
+	for _, result := range results {
+		scanResult(scanner, result)
+	}
+	scanner.Report()
+
+	// Write the variables either per organization or into a single combined file
+	var outputFiles []string
+	if viper.GetBool("GHMV_PER_ORG_FILES") {
+		for _, result := range results {
+			if len(result.variables) == 0 {
+				continue
+			}
+			result.outputFile = result.organization + "_variables.csv"
+			if err := writeVariablesCSV(result.outputFile, result.variables); err != nil {
+				return err
+			}
+			outputFiles = append(outputFiles, result.outputFile)
+		}
+		for _, result := range results {
+			if len(result.secrets) == 0 {
+				continue
+			}
+			secretsFile := secretsOutputFile(result.organization + "_variables.csv")
+			if err := writeSecretsCSV(secretsFile, result.secrets); err != nil {
+				return err
+			}
+			outputFiles = append(outputFiles, secretsFile)
+		}
+		for _, result := range results {
+			if len(result.effective) == 0 {
+				continue
+			}
+			effectiveFile := effectiveValuesOutputFile(result.organization + "_variables.csv")
+			if err := writeEffectiveValuesCSV(effectiveFile, result.effective); err != nil {
+				return err
+			}
+			outputFiles = append(outputFiles, effectiveFile)
+		}
+	} else {
+		var allVariables []map[string]string
+		for _, result := range results {
+			allVariables = append(allVariables, result.variables...)
+		}
+		if len(allVariables) > 0 {
+			outputFile := combinedOutputFile(organizations)
+			if err := writeVariablesCSV(outputFile, allVariables); err != nil {
+				return err
+			}
+			for _, result := range results {
+				if len(result.variables) > 0 {
+					result.outputFile = outputFile
+				}
+			}
+			outputFiles = append(outputFiles, outputFile)
+		}
+
+		var allSecrets []map[string]string
+		for _, result := range results {
+			allSecrets = append(allSecrets, result.secrets...)
+		}
+		if len(allSecrets) > 0 {
+			secretsFile := secretsOutputFile(combinedOutputFile(organizations))
+			if err := writeSecretsCSV(secretsFile, allSecrets); err != nil {
+				return err
+			}
+			outputFiles = append(outputFiles, secretsFile)
+		}
+
+		var allEffective []effectiveValue
+		for _, result := range results {
+			allEffective = append(allEffective, result.effective...)
+		}
+		if len(allEffective) > 0 {
+			effectiveFile := effectiveValuesOutputFile(combinedOutputFile(organizations))
+			if err := writeEffectiveValuesCSV(effectiveFile, allEffective); err != nil {
+				return err
+			}
+			outputFiles = append(outputFiles, effectiveFile)
+		}
+	}
+
+	// Tally the totals across every organization
+	var totalRepos, successful, failed, variablesWritten, secretsWritten, failedOrgs, conflicts int
+	for _, result := range results {
+		totalRepos += result.repositories
+		successful += result.successful
+		failed += result.failed
+		variablesWritten += len(result.variables)
+		secretsWritten += len(result.secrets)
+		conflicts += reportConflicts(result.effective)
+		if result.err != nil || result.orgErr != nil {
+			failedOrgs++
+		}
+	}
+
+	// Exit if no variables found
+	if variablesWritten == 0 && secretsWritten == 0 && failed == 0 && failedOrgs == 0 && len(scanner.Findings()) == 0 {
+		pterm.Info.Println("No variables found to export.")
+		return nil
+	}
+
+	if len(results) > 1 {
+		printOrganizationSummary(results)
+	}
+
+	// Print summary
+	summary := output.NewSummary("export", "Export Summary")
+	if len(results) > 1 {
+		summary.Add("", "Total organizations", "organizations", len(results))
+	}
+	summary.Add("", "Total repositories found", "repositories", totalRepos)
+	summary.Add("✅", "Successfully processed repositories", "processed", successful)
+	summary.Add("❌", "Failed to process repositories", "failed", failed)
+	if failedOrgs > 0 {
+		summary.Add("❌", "Failed organizations", "failed_organizations", failedOrgs)
+	}
+	summary.Add("📝", "Total variables exported", "variables", variablesWritten)
+	if includeSecrets {
+		summary.Add("🔐", "Total secret names exported", "secrets", secretsWritten)
+		var deniedStores []string
+		for _, result := range results {
+			for _, store := range result.deniedStores {
+				deniedStores = append(deniedStores, fmt.Sprintf("%s secrets in %s", store, result.organization))
+			}
+		}
+		if len(deniedStores) > 0 {
+			summary.AddList("⚠️ ", "Secret stores skipped (no access)", "denied_secret_stores", deniedStores)
+		}
+	}
+	if effectiveValues {
+		summary.Add("⚠️ ", "Variables with conflicting values across scopes", "conflicts", conflicts)
+	}
+	scanner.Summarize(summary)
+	summary.AddList("📁", "Output files", "output_files", outputFiles)
+	summary.Add("🕐", "Total time", "duration", time.Since(start).Round(time.Second).String())
+
+	if failed > 0 || failedOrgs > 0 {
+		summary.Print(output.StatusFailed)
+		output.Failed(fmt.Sprintf("export completed with %d failed repositories and %d failed organizations, some variables may not have been exported", failed, failedOrgs))
+		logging.Exit(1)
+	}
+	if scanner.Blocked() > 0 {
+		summary.Print(output.StatusFailed)
+		output.Failed(fmt.Sprintf("export withheld %d variables that look like credentials, store them as secrets or exclude them with --secret-scan-ignore", scanner.Blocked()))
+		logging.Exit(1)
+	}
+
+	summary.Print(output.StatusSuccess)
+	output.Message("✅", "Export completed successfully!")
+	return nil
+}
+
+// Builds the list of organizations to export from flags, an organizations file, or an enterprise
+func resolveOrganizations(token, hostname string) ([]string, error) {
+	var organizations []string
+	seen := make(map[string]bool)
+	add := func(organization string) {
+		organization = strings.TrimSpace(organization)
+		if organization != "" && !seen[organization] {
+			seen[organization] = true
+			organizations = append(organizations, organization)
+		}
+	}
+
+	for _, organization := range strings.Split(viper.GetString("source-organization"), ",") {
+		add(organization)
+	}
+
+	if organizationsFile := viper.GetString("organizations-file"); organizationsFile != "" {
+		fileOrgs, err := readOrganizationsFile(organizationsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, organization := range fileOrgs {
+			add(organization)
+		}
+	}
+
+	if enterprise := viper.GetString("enterprise"); enterprise != "" {
+		pterm.Info.Printf("Fetching organizations for enterprise %s...\n", enterprise)
+		enterpriseOrgs, err := api.FetchEnterpriseOrganizations(enterprise, token, hostname)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch enterprise organizations: %w", err)
+		}
+		pterm.Info.Printf("Found %d organizations in enterprise %s\n", len(enterpriseOrgs), enterprise)
+		for _, organization := range enterpriseOrgs {
+			add(organization)
+		}
+	}
+
+	return organizations, nil
+}
+
+// Lets the operator pick the organizations to export from those the token's user belongs to
+func selectOrganizations(token, hostname string) ([]string, error) {
+	organizations, err := api.FetchUserOrganizations(token, hostname)
+	if err != nil {
+		return nil, err
+	}
+	if len(organizations) == 0 {
+		return nil, fmt.Errorf("the token does not belong to any organizations")
+	}
+	return wizard.SelectMany("Organizations to export", organizations)
+}
+
+// Reads organizations from a file, one per line, ignoring blank lines and # comments
+func readOrganizationsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var organizations []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		organizations = append(organizations, line)
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read file %s: %w", path, err)
+	}
+	return organizations, nil
+}
 
-	var allVariables []map[string]string
+// Picks the combined output file name, honoring an explicit --output-file
+func combinedOutputFile(organizations []string) string {
+	if outputFile := viper.GetString("output-file"); outputFile != "" {
+		return outputFile
+	}
+	if enterprise := viper.GetString("enterprise"); enterprise != "" {
+		return enterprise + "_variables.csv"
+	}
+	if len(organizations) == 1 {
+		return organizations[0] + "_variables.csv"
+	}
+	return "variables.csv"
+}
+
+// Derives the secrets inventory file name from the variables output file name
+func secretsOutputFile(variablesFile string) string {
+	if strings.HasSuffix(variablesFile, "_variables.csv") {
+		return strings.TrimSuffix(variablesFile, "_variables.csv") + "_secrets.csv"
+	}
+	return strings.TrimSuffix(variablesFile, filepath.Ext(variablesFile)) + "_secrets.csv"
+}
+
+// Parses the comma-separated list of secret stores to export, defaulting to every store
+func resolveSecretStores(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return []string{api.SecretStoreActions, api.SecretStoreDependabot, api.SecretStoreCodespaces}, nil
+	}
+
+	var stores []string
+	for _, store := range strings.Split(value, ",") {
+		store = strings.ToLower(strings.TrimSpace(store))
+		switch store {
+		case "":
+			continue
+		case api.SecretStoreActions, api.SecretStoreDependabot, api.SecretStoreCodespaces:
+			stores = append(stores, store)
+		default:
+			return nil, fmt.Errorf("unknown secret store %q (expected actions, dependabot, or codespaces)", store)
+		}
+	}
+	return stores, nil
+}
+
+// Exports the organization and repository variables of a single organization,
+// and the names of its secrets in each of the given secret stores. When repositories are
+// selected, only those are exported. With effective values, the environment variables and
+// selected repositories needed to compute them are read as well.
+func exportOrganization(organization, token, hostname string, secretStores, selected []string, effective bool) *orgResult {
+	result := &orgResult{organization: organization}
 
 	// Fetch organization variables
 	pterm.Info.Printf("Fetching organization variables for %s...", organization)
 	orgVariables, err := api.FetchOrgVariables(organization, token, hostname)
 	if err != nil {
-		pterm.Error.Printf("Warning: Failed to fetch organization variables: %v\n", err)
+		output.Error("Failed to fetch organization variables for %s: %v", organization, err)
+		result.orgErr = fmt.Errorf("failed to fetch organization variables: %w", err)
 	} else {
 		pterm.Success.Printf("Found %d organization variables\n", len(orgVariables))
-		allVariables = append(allVariables, orgVariables...)
+		result.variables = append(result.variables, orgVariables...)
+		result.selectedRepos, err = fetchSelectedRepos(organization, orgVariables, token, hostname)
+		if err != nil {
+			output.Error("Failed to fetch selected repositories of organization variables for %s: %v", organization, err)
+			result.orgErr = fmt.Errorf("failed to fetch selected repositories: %w", err)
+		}
+	}
+
+	for _, store := range secretStores {
+		pterm.Info.Printf("Fetching organization %s secrets for %s...", store, organization)
+		orgSecrets, err := api.FetchOrgSecrets(store, organization, token, hostname)
+		if api.IsForbidden(err) {
+			result.denyStore(store, err)
+		} else if err != nil {
+			output.Error("Failed to fetch organization %s secrets for %s: %v", store, organization, err)
+		} else {
+			pterm.Success.Printf("Found %d organization %s secrets\n", len(orgSecrets), store)
+			result.secrets = append(result.secrets, orgSecrets...)
+		}
 	}
 
 	// Fetch repositories
 	pterm.Info.Printf("Fetching repository list for %s...\n", organization)
-	repos, err := api.FetchAllRepositories(organization, token, hostname)
+	catalog, err := api.FetchRepoCatalog(organization, token, hostname)
 	if err != nil {
-		return fmt.Errorf("failed to fetch repositories: %w", err)
+		output.Error("Failed to fetch repositories for %s: %v", organization, err)
+		result.err = fmt.Errorf("failed to fetch repositories: %w", err)
+		return result
+	}
+	repos := catalog.Names()
+	if selected != nil {
+		repos = selected
 	}
+	result.repositories = len(repos)
 	pterm.Info.Printf("Found %d repositories\n", len(repos))
 
 	// Process each repository
-	var successful, failed int
+	progress := output.StartProgress("Scanning "+organization, len(repos))
 	for _, repo := range repos {
-		pterm.Info.Printf("Querying Actions API for variables in %s...\n", repo)
+		output.Detail(pterm.Info, "Querying Actions API for variables in %s...\n", repo)
 		repoVariables, err := api.FetchRepoVariables(organization, repo, token, hostname)
 		if err != nil {
-			pterm.Error.Printf("Warning: Failed to fetch variables for repo %s: %v\n", repo, err)
-			failed++
+			output.Error("Failed to fetch variables for repo %s: %v", repo, err)
+			output.Event("repository", map[string]any{"organization": organization, "repository": repo, "outcome": "failed"})
+			result.failed++
+			progress.Failed()
 			continue
 		}
 
 		if len(repoVariables) > 0 {
-			allVariables = append(allVariables, repoVariables...)
-			pterm.Success.Printf("Found %d variables in repository %s\n", len(repoVariables), repo)
-			successful++
-		} else {
-			successful++
+			result.variables = append(result.variables, repoVariables...)
+			output.Detail(pterm.Success, "Found %d variables in repository %s\n", len(repoVariables), repo)
 		}
+
+		if effective {
+			scope, err := collectRepoScope(organization, repo, repoVariables, catalog, token, hostname)
+			if err != nil {
+				output.Error("Failed to fetch environment variables for repo %s: %v", repo, err)
+				output.Event("repository", map[string]any{"organization": organization, "repository": repo, "outcome": "failed"})
+				result.failed++
+				progress.Failed()
+				continue
+			}
+			result.scopes = append(result.scopes, scope)
+		}
+
+		if len(secretStores) > 0 {
+			repoSecrets, err := exportRepoSecrets(result, repo, token, hostname, secretStores)
+			if err != nil {
+				output.Error("Failed to fetch secrets for repo %s: %v", repo, err)
+				output.Event("repository", map[string]any{"organization": organization, "repository": repo, "outcome": "failed"})
+				result.failed++
+				progress.Failed()
+				continue
+			}
+			if len(repoSecrets) > 0 {
+				result.secrets = append(result.secrets, repoSecrets...)
+				output.Detail(pterm.Success, "Found %d secrets in repository %s\n", len(repoSecrets), repo)
+			}
+		}
+		output.Event("repository", map[string]any{"organization": organization, "repository": repo, "outcome": "exported", "variables": len(repoVariables)})
+		result.successful++
+		progress.Succeeded()
 	}
+	progress.Stop()
 
-	// Exit if no variables found
-	if len(allVariables) == 0 {
-		pterm.Info.Println("No variables found to export.")
-		return nil
+	// Tag every variable and secret with its source organization
+	for _, variable := range result.variables {
+		variable["Organization"] = organization
+	}
+	for _, secret := range result.secrets {
+		secret["Organization"] = organization
+	}
+
+	// Without the organization variables and their selected repositories the effective values
+	// would be wrong, so the report is left out for the organization
+	if effective && result.orgErr != nil {
+		output.Warning("Skipping the effective value report for %s: %v", organization, result.orgErr)
+	} else if effective {
+		result.effective = computeEffectiveValues(result)
+	}
+
+	return result
+}
+
+// Checks the variables of an organization for values that look like credentials and withholds the
+// values the policy blocks. Withheld variables stay in the CSV, so that a sync with prune does not
+// delete them from the target. Environment variables only appear in the effective value report.
+func scanResult(scanner *secretscan.Scanner, result *orgResult) {
+	for _, variable := range result.variables {
+		repository := variable["Scope"]
+		if repository == api.EntityTypeOrg {
+			repository = ""
+		}
+		if !scanner.Check(variable["Name"], result.organization, repository, "", variable["Value"]) {
+			variable["Value"] = secretscan.WithheldValue
+		}
+	}
+
+	for _, scope := range result.scopes {
+		for _, env := range sortedNames(scope.environments) {
+			for _, variable := range scope.environments[env] {
+				scanner.Check(variable["Name"], result.organization, scope.name, env, variable["Value"])
+			}
+		}
 	}
+	for i := range result.effective {
+		value := &result.effective[i]
+		if scanner.Blocks(value.name, value.value) {
+			value.value = secretscan.WithheldValue
+		}
+		for j, overridden := range value.overridden {
+			if scanner.Blocks(value.name, overridden.value) {
+				value.overridden[j].value = secretscan.WithheldValue
+			}
+		}
+	}
+}
 
-	// Create and write to CSV file
-	outputFile := organization + "_variables.csv"
+// Retrieves the selected repositories of every organization variable with selected visibility and
+// records them in the variable's SelectedRepositories column
+func fetchSelectedRepos(organization string, orgVariables []map[string]string, token, hostname string) (map[string]map[string]bool, error) {
+	selected := make(map[string]map[string]bool)
+	for _, variable := range orgVariables {
+		if variable["Visibility"] != "selected" {
+			continue
+		}
+		repos, err := api.FetchSelectedReposForOrgVariable(organization, variable["Name"], token, hostname)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(repos)
+		variable["SelectedRepositories"] = strings.Join(repos, api.SelectedReposSeparator)
+		selected[variable["Name"]] = make(map[string]bool)
+		for _, repo := range repos {
+			selected[variable["Name"]][strings.ToLower(repo)] = true
+		}
+	}
+	return selected, nil
+}
+
+// Writes variables to a CSV file
+func writeVariablesCSV(outputFile string, variables []map[string]string) error {
 	file, err := os.Create(outputFile)
 	if err != nil {
 		return fmt.Errorf("cannot create file %s: %w", outputFile, err)
@@ -81,39 +612,141 @@ func ExportVariables() error {
 	defer writer.Flush()
 
 	// Write header
-	if err := writer.Write([]string{"Name", "Value", "Scope", "Visibility"}); err != nil {
+	if err := writer.Write([]string{"Name", "Value", "Scope", "Visibility", "Organization", "SelectedRepositories"}); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
 	// Write variables
-	variablesWritten := 0
-	for _, variable := range allVariables {
+	for _, variable := range variables {
 		if name, ok := variable["Name"]; ok && name != "" {
 			value := variable["Value"]
 			scope := variable["Scope"]
 			visibility := variable["Visibility"]
-			if err := writer.Write([]string{name, value, scope, visibility}); err != nil {
+			organization := variable["Organization"]
+			selectedRepos := variable["SelectedRepositories"]
+			if err := writer.Write([]string{name, value, scope, visibility, organization, selectedRepos}); err != nil {
 				return fmt.Errorf("failed to write variable to CSV: %w", err)
 			}
-			variablesWritten++
 		}
 	}
-	spinner.Success()
-	// Print summary
-	fmt.Printf("\n📊 Export Summary:\n")
-	fmt.Printf("Total repositories found: %d\n", len(repos))
-	fmt.Printf("✅ Successfully processed: %d repositories\n", successful)
-	fmt.Printf("❌ Failed to process: %d repositories\n", failed)
-	fmt.Printf("📝 Total variables exported: %d\n", variablesWritten)
-	fmt.Printf("📁 Output file: %s\n", outputFile)
-	fmt.Printf("🕐 Total time: %v\n", time.Since(start).Round(time.Second))
+	return nil
+}
+
+// Retrieves the repository-level secret names of a repository in each secret store,
+// and its environment-level Actions secret names. A store the token is not allowed to read is
+// skipped with a warning for the rest of the organization instead of failing the repository.
+func exportRepoSecrets(result *orgResult, repo, token, hostname string, secretStores []string) ([]map[string]string, error) {
+	organization := result.organization
+	var secrets []map[string]string
+	includeActions := false
+	for _, store := range secretStores {
+		if result.storeDenied(store) {
+			continue
+		}
+		repoSecrets, err := api.FetchRepoSecrets(store, organization, repo, token, hostname)
+		if api.IsForbidden(err) {
+			result.denyStore(store, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, repoSecrets...)
+		if store == api.SecretStoreActions {
+			includeActions = true
+		}
+	}
+	if !includeActions {
+		return secrets, nil
+	}
+
+	environments, err := api.FetchEnvironments(organization, repo, token, hostname)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range environments {
+		envSecrets, err := api.FetchEnvSecrets(organization, repo, env, token, hostname)
+		if api.IsForbidden(err) {
+			result.denyStore(api.SecretStoreActions, err)
+			return secrets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, envSecrets...)
+	}
+	return secrets, nil
+}
+
+// Reports whether the token was denied access to a secret store of the organization
+func (r *orgResult) storeDenied(store string) bool {
+	for _, denied := range r.deniedStores {
+		if denied == store {
+			return true
+		}
+	}
+	return false
+}
+
+// Skips a secret store the token cannot read for the rest of the organization, warning once
+func (r *orgResult) denyStore(store string, err error) {
+	if r.storeDenied(store) {
+		return
+	}
+	r.deniedStores = append(r.deniedStores, store)
+	output.Warning("Skipping %s secrets in %s: the token is not allowed to read them (%v)", store, r.organization, err)
+}
+
+// Writes the secrets inventory to a CSV file. Secret values cannot be exported, only names and settings.
+func writeSecretsCSV(outputFile string, secrets []map[string]string) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("cannot create file %s: %w", outputFile, err)
+	}
+	defer file.Close()
 
-	if failed > 0 {
-		fmt.Printf("\n🛑 Export completed with some failures. Some variables may not have been exported.\n")
-		fmt.Printf("export completed with %d failed repositories", failed)
-		os.Exit(1)
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	// Write header
+	if err := writer.Write([]string{"Name", "Scope", "Environment", "Visibility", "SelectedRepositories", "Organization", "Store"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
-	fmt.Println("\n✅ Export completed successfully!")
+	// Write secrets
+	for _, secret := range secrets {
+		row := []string{secret["Name"], secret["Scope"], secret["Environment"], secret["Visibility"], secret["SelectedRepositories"], secret["Organization"], secret["Store"]}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write secret to CSV: %w", err)
+		}
+	}
 	return nil
 }
+
+// Prints a per-organization summary table
+func printOrganizationSummary(results []*orgResult) {
+	data := pterm.TableData{{"Organization", "Repositories", "Processed", "Failed", "Variables", "Output file"}}
+	for _, result := range results {
+		outputFile := result.outputFile
+		err := result.err
+		if err == nil {
+			err = result.orgErr
+		}
+		if err != nil {
+			outputFile = err.Error()
+			if output.Mode() == output.ModePretty {
+				outputFile = "❌ " + outputFile
+			}
+		}
+		data = append(data, []string{
+			result.organization,
+			fmt.Sprintf("%d", result.repositories),
+			fmt.Sprintf("%d", result.successful),
+			fmt.Sprintf("%d", result.failed),
+			fmt.Sprintf("%d", len(result.variables)),
+			outputFile,
+		})
+	}
+	fmt.Println()
+	pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
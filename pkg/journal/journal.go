@@ -0,0 +1,123 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// Entry records a single change made to a target variable during a sync. PreviousSelectedRepos
+// holds the repositories an organization variable with selected visibility was available to.
+type Entry struct {
+	Action                string    `json:"action"`
+	Hostname              string    `json:"hostname,omitempty"`
+	Organization          string    `json:"organization"`
+	Repository            string    `json:"repository,omitempty"`
+	Name                  string    `json:"name"`
+	Value                 string    `json:"value"`
+	Visibility            string    `json:"visibility,omitempty"`
+	PreviousValue         string    `json:"previous_value,omitempty"`
+	PreviousVisibility    string    `json:"previous_visibility,omitempty"`
+	PreviousSelectedRepos []string  `json:"previous_selected_repos,omitempty"`
+	Timestamp             time.Time `json:"timestamp"`
+}
+
+// Writer appends journal entries to a JSON Lines file as changes are made, so an
+// interrupted sync still leaves a usable journal behind
+type Writer struct {
+	path    string
+	file    *os.File
+	entries int
+}
+
+// Create opens a new journal file for writing, refusing to overwrite an existing one. It is
+// created up front so that an unwritable path fails the sync before any variable is changed.
+func Create(path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create journal file %s: %w", path, err)
+	}
+	return &Writer{path: path, file: file}, nil
+}
+
+// Path returns the journal file path
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Entries returns the number of entries recorded so far
+func (w *Writer) Entries() int {
+	return w.entries
+}
+
+// Record appends an entry to the journal
+func (w *Writer) Record(entry Entry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal file %s: %w", w.path, err)
+	}
+	w.entries++
+	return nil
+}
+
+// Close closes the journal file, removing it if nothing was recorded
+func (w *Writer) Close() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if w.entries == 0 {
+		return os.Remove(w.path)
+	}
+	return nil
+}
+
+// Load reads every entry from a journal file in the order they were recorded
+func Load(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open journal file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("journal file %s line %d: %w", path, lineNumber, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read journal file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// DefaultPath builds a timestamped journal file name for a sync run
+func DefaultPath(organization string, start time.Time) string {
+	if organization == "" {
+		organization = "sync"
+	}
+	return fmt.Sprintf("%s_journal_%s.jsonl", organization, start.Format("20060102-150405"))
+}
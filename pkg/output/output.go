@@ -0,0 +1,161 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+)
+
+// Output modes
+const (
+	ModeAuto   = "auto"
+	ModePretty = "pretty"
+	ModePlain  = "plain"
+	ModeJSON   = "json"
+)
+
+var (
+	mode = ModePretty
+	// events receives the NDJSON events in JSON mode
+	events io.Writer = os.Stdout
+	// actions is set when running in a GitHub Actions job
+	actions   bool
+	eventsMu  sync.Mutex
+	groupOpen bool
+)
+
+// Setup selects the output mode. In auto mode pretty output is used on a terminal and plain
+// output otherwise, such as in CI logs. Plain output has no colors, spinners, or emoji. In JSON
+// mode standard output carries only NDJSON events, and all other output moves to standard error.
+func Setup(requested string) error {
+	switch strings.ToLower(requested) {
+	case "", ModeAuto:
+		mode = ModePlain
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			mode = ModePretty
+		}
+	case ModePretty:
+		mode = ModePretty
+	case ModePlain:
+		mode = ModePlain
+	case ModeJSON:
+		mode = ModeJSON
+	default:
+		return fmt.Errorf("invalid output mode %q (expected plain, json, or pretty)", requested)
+	}
+	actions = os.Getenv("GITHUB_ACTIONS") == "true"
+
+	if mode != ModePretty {
+		pterm.DisableStyling()
+	}
+	if mode == ModeJSON {
+		events = os.Stdout
+		os.Stdout = os.Stderr
+		pterm.SetDefaultOutput(os.Stderr)
+		// The prefix printers captured standard output when pterm was initialized
+		for _, printer := range []*pterm.PrefixPrinter{&pterm.Info, &pterm.Success, &pterm.Warning, &pterm.Error, &pterm.Fatal, &pterm.Debug, &pterm.Description} {
+			printer.Writer = os.Stderr
+		}
+	}
+	return nil
+}
+
+// Mode returns the output mode in use
+func Mode() string {
+	return mode
+}
+
+// Event writes a progress event as a line of JSON. Outside of JSON mode it does nothing.
+// Fields must never contain tokens or variable values.
+func Event(kind string, fields map[string]any) {
+	if mode != ModeJSON {
+		return
+	}
+	event := map[string]any{"event": kind, "time": time.Now().UTC().Format(time.RFC3339)}
+	for key, value := range fields {
+		event[key] = value
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	fmt.Fprintln(events, string(line))
+}
+
+// Group starts a collapsible group of log lines in GitHub Actions
+func Group(title string) {
+	if !actions {
+		return
+	}
+	if groupOpen {
+		EndGroup()
+	}
+	fmt.Printf("::group::%s\n", escapeCommand(title))
+	groupOpen = true
+}
+
+// EndGroup ends the group started by Group
+func EndGroup() {
+	if !actions || !groupOpen {
+		return
+	}
+	fmt.Println("::endgroup::")
+	groupOpen = false
+}
+
+// Error prints an error. In GitHub Actions it becomes an error annotation of the job.
+func Error(format string, args ...any) {
+	message := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+	printMu.Lock()
+	defer printMu.Unlock()
+	if actions {
+		fmt.Printf("::error::%s\n", escapeCommand(message))
+		return
+	}
+	pterm.Error.Println(message)
+}
+
+// Warning prints a warning. In GitHub Actions it becomes a warning annotation of the job.
+func Warning(format string, args ...any) {
+	message := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+	printMu.Lock()
+	defer printMu.Unlock()
+	if actions {
+		fmt.Printf("::warning::%s\n", escapeCommand(message))
+		return
+	}
+	pterm.Warning.Println(message)
+}
+
+// Escapes a message for a GitHub Actions workflow command
+func escapeCommand(message string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(message)
+}
+
+// Message prints a closing message, with its emoji in pretty mode
+func Message(icon, message string) {
+	if mode == ModePretty {
+		fmt.Printf("\n%s %s\n", icon, message)
+		return
+	}
+	fmt.Printf("\n%s\n", message)
+}
+
+// Failed prints the closing message of a command that failed. In GitHub Actions it also
+// becomes an error annotation of the job.
+func Failed(message string) {
+	EndGroup()
+	Message("🛑", message)
+	if actions {
+		fmt.Printf("::error::%s\n", escapeCommand(message))
+	}
+}
@@ -0,0 +1,169 @@
+package output
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// How often plain and JSON output report progress, at most
+const progressInterval = 10 * time.Second
+
+var (
+	// verbose enables per-item detail lines
+	verbose bool
+	// printMu serializes console output with progress bar updates, since pterm redraws every
+	// active bar whenever something is printed
+	printMu sync.Mutex
+)
+
+// SetVerbose turns per-item detail lines on or off
+func SetVerbose(enabled bool) {
+	verbose = enabled
+}
+
+// Detail prints a per-item detail line, only at verbose level
+func Detail(printer pterm.PrefixPrinter, format string, args ...any) {
+	if verbose {
+		printMu.Lock()
+		defer printMu.Unlock()
+		printer.Printf(format, args...)
+	}
+}
+
+// Progress tracks the items of a long-running step. In pretty mode it shows a progress bar with
+// the processed, failed, and skipped counts, throughput, and an ETA. Plain output prints the
+// same as a line every few seconds, and JSON output emits progress events. Items may be
+// reported from several goroutines.
+type Progress struct {
+	title      string
+	total      int
+	processed  int
+	failed     int
+	skipped    int
+	start      time.Time
+	lastReport time.Time
+	bar        *pterm.ProgressbarPrinter
+}
+
+// StartProgress starts tracking a step with a known number of items
+func StartProgress(title string, total int) *Progress {
+	p := &Progress{title: title, total: total, start: time.Now()}
+	p.lastReport = p.start
+	if mode == ModePretty && total > 0 {
+		// The elapsed time is part of the title, so the bar is only redrawn under the lock
+		p.bar, _ = pterm.DefaultProgressbar.
+			WithTotal(total).
+			WithShowElapsedTime(false).
+			WithRemoveWhenDone(true).
+			Start(p.status())
+	}
+	return p
+}
+
+// Succeeded records an item that was processed
+func (p *Progress) Succeeded() {
+	p.record(&p.processed)
+}
+
+// Failed records an item that failed
+func (p *Progress) Failed() {
+	p.record(&p.failed)
+}
+
+// Skipped records an item that was skipped
+func (p *Progress) Skipped() {
+	p.record(&p.skipped)
+}
+
+func (p *Progress) record(counter *int) {
+	printMu.Lock()
+	defer printMu.Unlock()
+	*counter++
+
+	if p.bar != nil {
+		p.bar.Title = p.status()
+		p.bar.Increment()
+		return
+	}
+	if p.done() < p.total && time.Since(p.lastReport) < progressInterval {
+		return
+	}
+	p.lastReport = time.Now()
+	p.report()
+}
+
+// Stop ends the progress bar and prints the final counts. Plain and JSON output already reported
+// them when the last item finished, unless the step stopped early.
+func (p *Progress) Stop() {
+	printMu.Lock()
+	defer printMu.Unlock()
+	if p.bar != nil {
+		p.bar.Stop()
+		p.bar = nil
+	}
+	if mode == ModePretty {
+		pterm.Info.Printf("%s: %s\n", p.title, p.counts())
+	} else if p.done() < p.total {
+		p.report()
+	}
+}
+
+// Reports progress as a line or an event
+func (p *Progress) report() {
+	if mode == ModeJSON {
+		Event("progress", map[string]any{
+			"step":       p.title,
+			"total":      p.total,
+			"processed":  p.processed,
+			"failed":     p.failed,
+			"skipped":    p.skipped,
+			"per_second": math.Round(p.throughput()*100) / 100,
+			"eta":        p.eta().String(),
+		})
+		return
+	}
+	fmt.Printf("%s: %d/%d, %s\n", p.title, p.done(), p.total, p.rate())
+}
+
+// Number of items finished so far
+func (p *Progress) done() int {
+	return p.processed + p.failed + p.skipped
+}
+
+// Formats the counts of the step
+func (p *Progress) counts() string {
+	return fmt.Sprintf("%d processed, %d failed, %d skipped", p.processed, p.failed, p.skipped)
+}
+
+// Formats the counts, throughput, and ETA
+func (p *Progress) rate() string {
+	return fmt.Sprintf("%s, %.1f/s, ETA %v", p.counts(), p.throughput(), p.eta())
+}
+
+// Formats the progress bar title
+func (p *Progress) status() string {
+	return fmt.Sprintf("%s (%s, elapsed %v)", p.title, p.rate(), time.Since(p.start).Round(time.Second))
+}
+
+// Items finished per second
+func (p *Progress) throughput() float64 {
+	elapsed := time.Since(p.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.done()) / elapsed
+}
+
+// Estimated time until every item is finished, from the throughput so far
+func (p *Progress) eta() time.Duration {
+	done := p.done()
+	if done == 0 || done >= p.total {
+		return 0
+	}
+	perItem := time.Since(p.start) / time.Duration(done)
+	return (perItem * time.Duration(p.total-done)).Round(time.Second)
+}
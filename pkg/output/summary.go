@@ -0,0 +1,117 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Final status of a command, reported in the JSON summary and the job summary
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusDryRun  = "dry-run"
+)
+
+// Summary collects the totals of a command and prints them in the selected output mode
+type Summary struct {
+	command string
+	title   string
+	lines   []summaryLine
+}
+
+type summaryLine struct {
+	icon  string
+	label string
+	key   string
+	value any
+	items []string
+}
+
+// NewSummary starts the summary of a command, such as "sync" titled "Sync Summary"
+func NewSummary(command, title string) *Summary {
+	return &Summary{command: command, title: title}
+}
+
+// Add adds a total. The key names it in the JSON summary; the icon is only shown in pretty mode.
+func (s *Summary) Add(icon, label, key string, value any) {
+	s.lines = append(s.lines, summaryLine{icon: icon, label: label, key: key, value: value})
+}
+
+// AddList adds the number of items followed by the items themselves
+func (s *Summary) AddList(icon, label, key string, items []string) {
+	s.lines = append(s.lines, summaryLine{icon: icon, label: label, key: key, value: len(items), items: items})
+}
+
+// Print prints the summary, emits it as a JSON event in JSON mode, and appends it to the job
+// summary when running in GitHub Actions
+func (s *Summary) Print(status string) {
+	EndGroup()
+
+	if mode == ModeJSON {
+		fields := map[string]any{"command": s.command, "status": status}
+		for _, line := range s.lines {
+			fields[line.key] = line.value
+			if line.items != nil {
+				fields[line.key+"_items"] = line.items
+			}
+		}
+		Event("summary", fields)
+	}
+
+	if mode == ModePretty {
+		fmt.Printf("\n📊 %s:\n", s.title)
+	} else {
+		fmt.Printf("\n%s:\n", s.title)
+	}
+	for _, line := range s.lines {
+		if mode == ModePretty && line.icon != "" {
+			fmt.Printf("%s %s: %v\n", line.icon, line.label, line.value)
+		} else {
+			fmt.Printf("%s: %v\n", line.label, line.value)
+		}
+		for _, item := range line.items {
+			fmt.Printf("   - %s\n", item)
+		}
+	}
+
+	if err := s.writeJobSummary(status); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write job summary: %v\n", err)
+	}
+}
+
+// Appends the summary as markdown to the file GitHub Actions shows on the run page
+func (s *Summary) writeJobSummary(status string) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if !actions || path == "" {
+		return nil
+	}
+
+	var markdown strings.Builder
+	icon := map[string]string{StatusSuccess: "✅", StatusFailed: "❌", StatusDryRun: "🔍"}[status]
+	fmt.Fprintf(&markdown, "### %s %s\n\n", icon, s.title)
+	fmt.Fprintf(&markdown, "Status: **%s**\n\n", status)
+	markdown.WriteString("| Metric | Value |\n|---|---|\n")
+	for _, line := range s.lines {
+		fmt.Fprintf(&markdown, "| %s | %v |\n", line.label, line.value)
+	}
+	for _, line := range s.lines {
+		if len(line.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&markdown, "\n<details><summary>%s</summary>\n\n", line.label)
+		for _, item := range line.items {
+			fmt.Fprintf(&markdown, "- %s\n", item)
+		}
+		markdown.WriteString("\n</details>\n")
+	}
+	markdown.WriteString("\n")
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(markdown.String())
+	return err
+}
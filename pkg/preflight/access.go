@@ -0,0 +1,237 @@
+package preflight
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// Access levels a token is checked for
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// Outcomes of a single access check
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// OAuth scopes a classic token needs for each access level
+var requiredScopes = map[string][]string{
+	AccessRead:  {"repo", "read:org"},
+	AccessWrite: {"repo", "admin:org"},
+}
+
+// OAuth scopes a classic token needs to write only repository variables
+var repoWriteScopes = []string{"repo"}
+
+// Endpoint describes a token and the organizations it is checked against
+type Endpoint struct {
+	// Label names the endpoint in the checklist, such as "Source" or "Target"
+	Label         string
+	Token         string
+	Hostname      string
+	Organizations []string
+	// SampleRepo is the repository read and write access is tested on. When empty, the first
+	// unarchived repository of each organization is used.
+	SampleRepo string
+	Access     string
+	// OrgWrite is set when organization variables or secrets are written, which requires an
+	// organization owner. Repository writes only need access to the repositories.
+	OrgWrite bool
+	// WriteTest writes and deletes a temporary variable to test repository write access. Only the
+	// doctor command sets it, so that a sync leaves no trace in the sample repository.
+	WriteTest bool
+}
+
+// Result is one line of the access checklist
+type Result struct {
+	Check  string
+	Status string
+	Detail string
+}
+
+// Runs every access check for an endpoint: token validity, OAuth scopes, organization membership
+// and role, organization variable access, and read and write access on a sample repository
+func CheckEndpoint(endpoint Endpoint) []Result {
+	var results []Result
+
+	info, err := api.FetchTokenInfo(endpoint.Token, endpoint.Hostname)
+	if err != nil {
+		return append(results, Result{"Token", StatusFail, err.Error()})
+	}
+	identity := info.Type
+	if info.Login != "" {
+		identity = fmt.Sprintf("%s for %s", info.Type, info.Login)
+	}
+	results = append(results, Result{"Token", StatusPass, identity})
+	results = append(results, checkScopes(info, endpoint.Access, endpoint.OrgWrite))
+
+	for _, org := range endpoint.Organizations {
+		results = append(results, checkMembership(info, endpoint, org))
+
+		// A sync that only writes repository variables does not need the organization variables
+		if err := api.ProbeOrgVariables(org, endpoint.Token, endpoint.Hostname); err != nil {
+			status := StatusFail
+			if endpoint.Access == AccessWrite && !endpoint.OrgWrite {
+				status = StatusWarn
+			}
+			results = append(results, Result{fmt.Sprintf("Read %s variables", org), status, err.Error()})
+		} else {
+			results = append(results, Result{fmt.Sprintf("Read %s variables", org), StatusPass, ""})
+		}
+
+		results = append(results, checkSampleRepo(endpoint, org)...)
+	}
+	return results
+}
+
+// Compares the X-OAuth-Scopes of a classic token with the scopes the access level needs.
+// Fine-grained and App tokens have no scopes, so their permissions are covered by the access tests.
+func checkScopes(info *api.TokenInfo, access string, orgWrite bool) Result {
+	if !info.HasScopes {
+		return Result{"OAuth scopes", StatusPass, "not applicable, permissions are verified by the access tests below"}
+	}
+
+	required := requiredScopes[access]
+	if access == AccessWrite && !orgWrite {
+		required = repoWriteScopes
+	}
+	var missing []string
+	for _, scope := range required {
+		if !info.HasScope(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return Result{"OAuth scopes", StatusFail, "missing " + strings.Join(missing, ", ")}
+	}
+	return Result{"OAuth scopes", StatusPass, strings.Join(info.Scopes, ", ")}
+}
+
+// Checks that the token's user is an active member of an organization. Writing organization
+// variables or secrets requires an owner.
+func checkMembership(info *api.TokenInfo, endpoint Endpoint, org string) Result {
+	check := fmt.Sprintf("Membership in %s", org)
+	if info.Type == api.TokenTypeAppInstallation {
+		return Result{check, StatusPass, "not applicable to App installation tokens"}
+	}
+
+	role, err := api.FetchOrgRole(org, endpoint.Token, endpoint.Hostname)
+	if err != nil {
+		// Fine-grained tokens without the members permission cannot read their own membership
+		if !info.HasScopes {
+			return Result{check, StatusWarn, fmt.Sprintf("could not verify: %v", err)}
+		}
+		return Result{check, StatusFail, err.Error()}
+	}
+	if role != "admin" {
+		if endpoint.Access == AccessWrite && endpoint.OrgWrite {
+			return Result{check, StatusFail, fmt.Sprintf("role is %s, writing organization variables requires an owner", role)}
+		}
+		if endpoint.Access == AccessWrite {
+			return Result{check, StatusPass, fmt.Sprintf("role is %s, only repository variables are written", role)}
+		}
+		return Result{check, StatusWarn, fmt.Sprintf("role is %s, organization variables may not be readable", role)}
+	}
+	return Result{check, StatusPass, "owner"}
+}
+
+// Tests read and, for write access, write access on the sample repository of an organization
+func checkSampleRepo(endpoint Endpoint, org string) []Result {
+	repo := endpoint.SampleRepo
+	if repo == "" {
+		catalog, err := api.FetchRepoCatalog(org, endpoint.Token, endpoint.Hostname)
+		if err != nil {
+			return []Result{{fmt.Sprintf("Sample repository in %s", org), StatusFail, err.Error()}}
+		}
+		for _, candidate := range catalog.Repositories {
+			if !candidate.Archived {
+				repo = candidate.Name
+				break
+			}
+		}
+		if repo == "" {
+			return []Result{{fmt.Sprintf("Sample repository in %s", org), StatusWarn, "no unarchived repositories to test"}}
+		}
+	}
+	location := org + "/" + repo
+
+	var results []Result
+	if _, err := api.FetchRepoVariables(org, repo, endpoint.Token, endpoint.Hostname); err != nil {
+		return append(results, Result{fmt.Sprintf("Read %s variables", location), StatusFail, err.Error()})
+	}
+	results = append(results, Result{fmt.Sprintf("Read %s variables", location), StatusPass, ""})
+
+	if endpoint.Access != AccessWrite || !endpoint.WriteTest {
+		return results
+	}
+	if err := api.ProbeRepoVariableWrite(org, repo, endpoint.Token, endpoint.Hostname); err != nil {
+		return append(results, Result{fmt.Sprintf("Write %s variables", location), StatusFail, err.Error()})
+	}
+	return append(results, Result{fmt.Sprintf("Write %s variables", location), StatusPass, ""})
+}
+
+// Prints the checklist of an endpoint
+func PrintChecklist(label string, results []Result) {
+	pretty := output.Mode() == output.ModePretty
+	output.Message("🔑", label+" access checklist:")
+	for _, result := range results {
+		output.Event("check", map[string]any{"endpoint": label, "check": result.Check, "status": result.Status, "detail": result.Detail})
+
+		icon := "✅"
+		switch result.Status {
+		case StatusWarn:
+			icon = "⚠️ "
+		case StatusFail:
+			icon = "❌"
+		}
+		if !pretty {
+			icon = strings.ToUpper(result.Status)
+		}
+		if result.Detail != "" {
+			fmt.Printf("%s %s: %s\n", icon, result.Check, result.Detail)
+		} else {
+			fmt.Printf("%s %s\n", icon, result.Check)
+		}
+	}
+}
+
+// Returns the number of failed checks
+func Failures(results []Result) int {
+	failures := 0
+	for _, result := range results {
+		if result.Status == StatusFail {
+			failures++
+		}
+	}
+	return failures
+}
+
+// CheckAccess prints the access checklist of each endpoint before a migration starts and fails
+// when any check fails
+func CheckAccess(endpoints ...Endpoint) error {
+	if viper.GetBool("SKIP_PREFLIGHT") {
+		return nil
+	}
+
+	failures := 0
+	for _, endpoint := range endpoints {
+		results := CheckEndpoint(endpoint)
+		PrintChecklist(endpoint.Label, results)
+		failures += Failures(results)
+	}
+	fmt.Println()
+	if failures > 0 {
+		pterm.Error.Println("Run the doctor command for details, or pass --skip-preflight to continue anyway.")
+		return fmt.Errorf("access preflight failed with %d failed checks", failures)
+	}
+	return nil
+}
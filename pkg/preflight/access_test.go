@@ -0,0 +1,32 @@
+package preflight
+
+import (
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+)
+
+func TestCheckScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		access   string
+		orgWrite bool
+		want     string
+	}{
+		{name: "read", scopes: []string{"repo", "read:org"}, access: AccessRead, want: StatusPass},
+		{name: "read without read:org", scopes: []string{"repo"}, access: AccessRead, want: StatusFail},
+		{name: "organization write", scopes: []string{"repo", "admin:org"}, access: AccessWrite, orgWrite: true, want: StatusPass},
+		{name: "organization write without admin:org", scopes: []string{"repo"}, access: AccessWrite, orgWrite: true, want: StatusFail},
+		{name: "repository write with repo alone", scopes: []string{"repo"}, access: AccessWrite, want: StatusPass},
+		{name: "repository write without repo", scopes: []string{"read:org"}, access: AccessWrite, want: StatusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &api.TokenInfo{Type: "classic", Scopes: tt.scopes, HasScopes: true}
+			if got := checkScopes(info, tt.access, tt.orgWrite); got.Status != tt.want {
+				t.Fatalf("checkScopes() = %s (%s), want %s", got.Status, got.Detail, tt.want)
+			}
+		})
+	}
+}
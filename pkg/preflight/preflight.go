@@ -0,0 +1,53 @@
+package preflight
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// Check detects the version of a GitHub instance before any work starts. It fails when a required
+// feature is unsupported and warns about optional features, which the caller should then disable.
+func Check(token, hostname string, required, optional []string) (*api.Capabilities, error) {
+	if viper.GetBool("SKIP_PREFLIGHT") {
+		return &api.Capabilities{Hostname: hostname}, nil
+	}
+
+	capabilities, err := api.DetectCapabilities(token, hostname)
+	if err != nil {
+		return nil, fmt.Errorf("preflight check failed: %w", err)
+	}
+	if !capabilities.IsEnterpriseServer() {
+		return capabilities, nil
+	}
+	pterm.Info.Printf("Detected GitHub Enterprise Server %s\n", capabilities.Version)
+
+	var missing []string
+	for _, feature := range required {
+		if !capabilities.Supports(feature) {
+			missing = append(missing, fmt.Sprintf("%s (%s)", feature, requirement(feature)))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("GitHub Enterprise Server %s does not support %s", capabilities.Version, strings.Join(missing, ", "))
+	}
+
+	for _, feature := range optional {
+		if !capabilities.Supports(feature) {
+			pterm.Warning.Printf("%s are not supported on GitHub Enterprise Server %s (%s) and will be skipped\n",
+				feature, capabilities.Version, requirement(feature))
+		}
+	}
+	return capabilities, nil
+}
+
+// Describes the GHES version a feature needs
+func requirement(feature string) string {
+	if minimum := api.FeatureMinimumVersion(feature); minimum != "" {
+		return "requires " + minimum + " or later"
+	}
+	return "not available on GitHub Enterprise Server"
+}
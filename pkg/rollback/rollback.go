@@ -0,0 +1,222 @@
+package rollback
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/journal"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// RollbackSync reverses the changes recorded in a sync journal
+func RollbackSync() error {
+	start := time.Now()
+
+	journalFile := viper.GetString("journal")
+	hostname := viper.GetString("target-hostname")
+	targetToken := viper.GetString("target-token")
+	dryRun := viper.GetBool("GHMV_DRY_RUN")
+
+	if journalFile == "" || targetToken == "" {
+		return fmt.Errorf("missing required parameters: journal file or target token")
+	}
+
+	entries, err := journal.Load(journalFile)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		pterm.Info.Println("Journal is empty, nothing to roll back.")
+		return nil
+	}
+
+	// Changes are undone in reverse order so that repeated changes unwind correctly
+	reversed := make([]journal.Entry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		reversed = append(reversed, entries[i])
+	}
+
+	// Refuse to roll back if anything changed in the target since the sync
+	pterm.Info.Println("Checking target for changes since the sync...")
+	drifted, err := findDrift(reversed, func(entry journal.Entry) (map[string]string, error) {
+		return getVariable(entry, targetToken, resolveHostname(entry, hostname))
+	})
+	if err != nil {
+		return err
+	}
+	if len(drifted) > 0 {
+		output.Error("Target has changed since the sync")
+		for _, message := range drifted {
+			pterm.Error.Println(message)
+		}
+		return fmt.Errorf("refusing to roll back: %d variables changed since the sync", len(drifted))
+	}
+	pterm.Success.Println("Target matches the journal")
+
+	// Print the rollback plan
+	output.Message("📋", fmt.Sprintf("Rollback plan (%s):", journalFile))
+	for _, entry := range reversed {
+		switch entry.Action {
+		case journal.ActionCreated:
+			fmt.Printf("  - delete  %s %s\n", location(entry), entry.Name)
+		case journal.ActionUpdated:
+			fmt.Printf("  ~ restore %s %s: %q -> %q\n", location(entry), entry.Name, entry.Value, entry.PreviousValue)
+		case journal.ActionDeleted:
+			fmt.Printf("  + recreate %s %s: %q\n", location(entry), entry.Name, entry.PreviousValue)
+		}
+	}
+
+	if dryRun {
+		output.Message("🔍", "Dry run: no changes were made.")
+		return nil
+	}
+
+	var stats struct {
+		deleted   int
+		restored  int
+		recreated int
+		failed    int
+	}
+
+	output.Group("Rolling back variables")
+	for _, entry := range reversed {
+		if err := undo(entry, targetToken, resolveHostname(entry, hostname)); err != nil {
+			output.Error("Failed to roll back %s %s: %v", location(entry), entry.Name, err)
+			output.Event("variable", map[string]any{"name": entry.Name, "location": location(entry), "outcome": "failed"})
+			stats.failed++
+			continue
+		}
+		output.Event("variable", map[string]any{"name": entry.Name, "location": location(entry), "outcome": "rolled-back", "action": entry.Action})
+		switch entry.Action {
+		case journal.ActionCreated:
+			pterm.Success.Printf("Deleted variable %s from %s\n", entry.Name, location(entry))
+			stats.deleted++
+		case journal.ActionUpdated:
+			pterm.Success.Printf("Restored variable %s in %s\n", entry.Name, location(entry))
+			stats.restored++
+		case journal.ActionDeleted:
+			pterm.Success.Printf("Recreated variable %s in %s\n", entry.Name, location(entry))
+			stats.recreated++
+		}
+	}
+
+	summary := output.NewSummary("rollback", "Rollback Summary")
+	summary.Add("", "Total journal entries", "total", len(entries))
+	summary.Add("🗑️ ", "Deleted", "deleted", stats.deleted)
+	summary.Add("♻️ ", "Restored", "restored", stats.restored)
+	summary.Add("➕", "Recreated", "recreated", stats.recreated)
+	summary.Add("❌", "Failed", "failed", stats.failed)
+	summary.Add("🕐", "Total time", "duration", time.Since(start).Round(time.Second).String())
+
+	if stats.failed > 0 {
+		summary.Print(output.StatusFailed)
+		output.Failed(fmt.Sprintf("rollback completed with %d failed variables", stats.failed))
+		logging.Exit(1)
+	}
+
+	summary.Print(output.StatusSuccess)
+	output.Message("✅", "Rollback completed successfully!")
+	return nil
+}
+
+// Compares the current target state, as read by getCurrent, with what the sync left behind and
+// describes every difference. getCurrent returns nil for a variable that does not exist.
+func findDrift(entries []journal.Entry, getCurrent func(journal.Entry) (map[string]string, error)) ([]string, error) {
+	var drifted []string
+	checked := make(map[string]bool)
+	for _, entry := range entries {
+		// Only the latest change to a variable describes its expected current state
+		key := location(entry) + "/" + entry.Name
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		current, err := getCurrent(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s %s: %w", location(entry), entry.Name, err)
+		}
+		// A pruned variable is expected to still be absent
+		if entry.Action == journal.ActionDeleted {
+			if current != nil {
+				drifted = append(drifted, fmt.Sprintf("%s %s was recreated since the sync", location(entry), entry.Name))
+			}
+			continue
+		}
+		switch {
+		case current == nil:
+			drifted = append(drifted, fmt.Sprintf("%s %s no longer exists", location(entry), entry.Name))
+		case current["Value"] != entry.Value:
+			drifted = append(drifted, fmt.Sprintf("%s %s value changed since the sync", location(entry), entry.Name))
+		case entry.Repository == "" && entry.Visibility != "" && current["Visibility"] != entry.Visibility:
+			drifted = append(drifted, fmt.Sprintf("%s %s visibility changed from %s to %s since the sync",
+				location(entry), entry.Name, entry.Visibility, current["Visibility"]))
+		}
+	}
+	return drifted, nil
+}
+
+// Reverses a single journal entry
+func undo(entry journal.Entry, token, hostname string) error {
+	switch entry.Action {
+	case journal.ActionCreated:
+		if entry.Repository == "" {
+			return api.DeleteOrgVariable(entry.Organization, entry.Name, token, hostname)
+		}
+		return api.DeleteRepoVariable(entry.Organization, entry.Repository, entry.Name, token, hostname)
+	case journal.ActionUpdated:
+		if entry.Repository == "" {
+			if err := api.UpdateOrgVariable(entry.Organization, entry.Name, entry.PreviousValue, entry.PreviousVisibility, token, hostname); err != nil {
+				return err
+			}
+			return restoreSelectedRepos(entry, token, hostname)
+		}
+		return api.UpdateRepoVariable(entry.Organization, entry.Repository, entry.Name, entry.PreviousValue, entry.PreviousVisibility, token, hostname)
+	case journal.ActionDeleted:
+		if entry.Repository == "" {
+			if err := api.AddOrgVariable(entry.Organization, entry.Name, entry.PreviousValue, entry.PreviousVisibility, token, hostname); err != nil {
+				return err
+			}
+			return restoreSelectedRepos(entry, token, hostname)
+		}
+		return api.AddRepoVariable(entry.Organization, entry.Repository, entry.Name, entry.PreviousValue, entry.PreviousVisibility, token, hostname)
+	}
+	return fmt.Errorf("unknown journal action %q", entry.Action)
+}
+
+// Restores the repositories selected for an organization variable with selected visibility.
+// Journals written before the selection was recorded leave it empty.
+func restoreSelectedRepos(entry journal.Entry, token, hostname string) error {
+	if entry.PreviousVisibility != "selected" || len(entry.PreviousSelectedRepos) == 0 {
+		return nil
+	}
+	return api.SetSelectedReposForOrgVariable(entry.Organization, entry.Name, entry.PreviousSelectedRepos, token, hostname)
+}
+
+// Retrieves the current target variable for a journal entry
+func getVariable(entry journal.Entry, token, hostname string) (map[string]string, error) {
+	if entry.Repository == "" {
+		return api.GetOrgVariable(entry.Organization, entry.Name, token, hostname)
+	}
+	return api.GetRepoVariable(entry.Organization, entry.Repository, entry.Name, token, hostname)
+}
+
+// Uses the hostname given on the command line, falling back to the one recorded in the journal
+func resolveHostname(entry journal.Entry, hostname string) string {
+	if hostname != "" {
+		return hostname
+	}
+	return entry.Hostname
+}
+
+// Returns the organization, or organization/repository, of a journal entry
+func location(entry journal.Entry) string {
+	if entry.Repository == "" {
+		return entry.Organization
+	}
+	return entry.Organization + "/" + entry.Repository
+}
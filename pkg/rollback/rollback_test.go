@@ -0,0 +1,85 @@
+package rollback
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-variables/pkg/journal"
+)
+
+func TestFindDrift(t *testing.T) {
+	created := journal.Entry{Action: journal.ActionCreated, Organization: "org", Repository: "app", Name: "ENV", Value: "prod"}
+	orgUpdated := journal.Entry{Action: journal.ActionUpdated, Organization: "org", Name: "URL", Value: "x", Visibility: "private"}
+	pruned := journal.Entry{Action: journal.ActionDeleted, Organization: "org", Repository: "app", Name: "OLD", PreviousValue: "y"}
+
+	tests := []struct {
+		name    string
+		entries []journal.Entry
+		current map[string]map[string]string
+		want    []string
+	}{
+		{
+			name:    "target unchanged",
+			entries: []journal.Entry{created, orgUpdated, pruned},
+			current: map[string]map[string]string{
+				"org/app/ENV": {"Value": "prod"},
+				"org/URL":     {"Value": "x", "Visibility": "private"},
+			},
+		},
+		{
+			name:    "variable deleted since the sync",
+			entries: []journal.Entry{created},
+			want:    []string{"org/app ENV no longer exists"},
+		},
+		{
+			name:    "value changed",
+			entries: []journal.Entry{created},
+			current: map[string]map[string]string{"org/app/ENV": {"Value": "staging"}},
+			want:    []string{"org/app ENV value changed since the sync"},
+		},
+		{
+			name:    "organization visibility changed",
+			entries: []journal.Entry{orgUpdated},
+			current: map[string]map[string]string{"org/URL": {"Value": "x", "Visibility": "all"}},
+			want:    []string{"org URL visibility changed from private to all since the sync"},
+		},
+		{
+			name:    "pruned variable recreated",
+			entries: []journal.Entry{pruned},
+			current: map[string]map[string]string{"org/app/OLD": {"Value": "y"}},
+			want:    []string{"org/app OLD was recreated since the sync"},
+		},
+		{
+			name: "only the latest change is compared",
+			entries: []journal.Entry{
+				{Action: journal.ActionUpdated, Organization: "org", Repository: "app", Name: "ENV", Value: "staging"},
+				created,
+			},
+			current: map[string]map[string]string{"org/app/ENV": {"Value": "staging"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drifted, err := findDrift(tt.entries, func(entry journal.Entry) (map[string]string, error) {
+				return tt.current[location(entry)+"/"+entry.Name], nil
+			})
+			if err != nil {
+				t.Fatalf("findDrift: %v", err)
+			}
+			if !reflect.DeepEqual(drifted, tt.want) {
+				t.Fatalf("got %v, want %v", drifted, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindDriftReadError(t *testing.T) {
+	entries := []journal.Entry{{Action: journal.ActionCreated, Organization: "org", Name: "ENV"}}
+	_, err := findDrift(entries, func(journal.Entry) (map[string]string, error) {
+		return nil, errors.New("forbidden")
+	})
+	if err == nil {
+		t.Fatal("findDrift() ignored a failed read")
+	}
+}
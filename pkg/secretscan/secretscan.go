@@ -0,0 +1,271 @@
+package secretscan
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/pterm/pterm"
+)
+
+// What happens to a variable whose value looks like a credential
+const (
+	PolicyOff     = "off"
+	PolicyWarn    = "warn"
+	PolicySuggest = "suggest"
+	PolicyBlock   = "block"
+)
+
+// Patterns a value can match
+const (
+	RuleGitHubToken  = "github-token"
+	RuleAWSAccessKey = "aws-access-key"
+	RuleAWSSecretKey = "aws-secret-key"
+	RuleJWT          = "jwt"
+	RulePrivateKey   = "private-key"
+	RuleHighEntropy  = "high-entropy"
+)
+
+var rules = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{RulePrivateKey, regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)},
+	{RuleGitHubToken, logging.GitHubTokenPattern},
+	{RuleAWSAccessKey, regexp.MustCompile(`\b(AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`)},
+	{RuleJWT, regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+}
+
+var (
+	// AWS secret access keys have no prefix, so they are only recognized in variables named after AWS
+	awsSecretKey = regexp.MustCompile(`^[A-Za-z0-9/+]{40}$`)
+	// Candidates for the entropy check are long runs of characters used by tokens and base64
+	entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_-]{32,}`)
+)
+
+// WithheldValue replaces the values the block policy withholds in export files. Sync recognizes it
+// and never writes it, but still counts the variable as present, so prune spares it.
+const WithheldValue = "[withheld]"
+
+// Strings with at least this many bits of entropy per character, and upper-case letters,
+// lower-case letters, and digits, look random. Hex digests and UUIDs stay below it.
+const entropyThreshold = 4.5
+
+// Finding is a variable whose value looks like a credential. It never holds the value.
+type Finding struct {
+	Name         string
+	Organization string
+	Repository   string
+	Environment  string
+	Rule         string
+	Blocked      bool
+}
+
+// Returns the organization, repository, and environment the variable is defined in
+func (f Finding) Location() string {
+	location := f.Organization
+	if f.Repository != "" {
+		location += "/" + f.Repository
+	}
+	if f.Environment != "" {
+		location += " (environment " + f.Environment + ")"
+	}
+	return location
+}
+
+// Returns the gh command that creates a secret in place of the variable. gh prompts for the value.
+func (f Finding) Suggestion() string {
+	switch {
+	case f.Environment != "":
+		return fmt.Sprintf("gh secret set %s --repo %s/%s --env %s", f.Name, f.Organization, f.Repository, f.Environment)
+	case f.Repository != "":
+		return fmt.Sprintf("gh secret set %s --repo %s/%s", f.Name, f.Organization, f.Repository)
+	}
+	return fmt.Sprintf("gh secret set %s --org %s", f.Name, f.Organization)
+}
+
+// Scanner checks variable values for credentials and applies the policy to them
+type Scanner struct {
+	policy   string
+	ignore   map[string]bool
+	findings []Finding
+}
+
+// New creates a scanner. Variables named in ignore are never flagged.
+func New(policy string, ignore []string) (*Scanner, error) {
+	switch strings.ToLower(policy) {
+	case "":
+		policy = PolicyWarn
+	case PolicyOff, PolicyWarn, PolicySuggest, PolicyBlock:
+		policy = strings.ToLower(policy)
+	default:
+		return nil, fmt.Errorf("unknown secret scan policy %q (expected off, warn, suggest, or block)", policy)
+	}
+	scanner := &Scanner{policy: policy, ignore: make(map[string]bool)}
+	for _, name := range ignore {
+		if name = strings.TrimSpace(name); name != "" {
+			scanner.ignore[strings.ToUpper(name)] = true
+		}
+	}
+	return scanner, nil
+}
+
+// Policy returns the policy in use
+func (s *Scanner) Policy() string {
+	return s.policy
+}
+
+// Check scans the value of a variable and records a finding when it looks like a credential.
+// It returns false when the policy blocks the variable and it must not be written.
+func (s *Scanner) Check(name, organization, repository, environment, value string) bool {
+	rule := s.detect(name, value)
+	if rule == "" {
+		return true
+	}
+	finding := Finding{
+		Name:         name,
+		Organization: organization,
+		Repository:   repository,
+		Environment:  environment,
+		Rule:         rule,
+		Blocked:      s.policy == PolicyBlock,
+	}
+	s.findings = append(s.findings, finding)
+	slog.Info("variable value looks like a credential", "variable", name, "location", finding.Location(), "rule", rule, "blocked", finding.Blocked)
+	output.Event("credential", map[string]any{
+		"name":         name,
+		"organization": organization,
+		"repository":   repository,
+		"environment":  environment,
+		"rule":         rule,
+		"blocked":      finding.Blocked,
+	})
+	return !finding.Blocked
+}
+
+// Blocks reports whether the policy withholds a value without recording a finding, for values
+// that were already checked and are repeated elsewhere, such as in the effective value report
+func (s *Scanner) Blocks(name, value string) bool {
+	return s.policy == PolicyBlock && s.detect(name, value) != ""
+}
+
+// Findings returns the findings in the order they were found
+func (s *Scanner) Findings() []Finding {
+	return s.findings
+}
+
+// Blocked returns the number of variables the policy withheld
+func (s *Scanner) Blocked() int {
+	blocked := 0
+	for _, finding := range s.findings {
+		if finding.Blocked {
+			blocked++
+		}
+	}
+	return blocked
+}
+
+// Returns the rule a value matches, or an empty string when it does not look like a credential
+func (s *Scanner) detect(name, value string) string {
+	if s.policy == PolicyOff || s.ignore[strings.ToUpper(name)] || value == "" {
+		return ""
+	}
+	return Detect(name, value)
+}
+
+// Detect returns the rule a variable value matches, or an empty string when it does not look
+// like a credential
+func Detect(name, value string) string {
+	for _, rule := range rules {
+		if rule.pattern.MatchString(value) {
+			return rule.name
+		}
+	}
+	if strings.Contains(strings.ToUpper(name), "AWS") && awsSecretKey.MatchString(strings.TrimSpace(value)) {
+		return RuleAWSSecretKey
+	}
+	for _, candidate := range entropyCandidate.FindAllString(value, -1) {
+		if mixedClasses(candidate) && entropy(candidate) >= entropyThreshold {
+			return RuleHighEntropy
+		}
+	}
+	return ""
+}
+
+// Reports whether a string has upper-case letters, lower-case letters, and digits
+func mixedClasses(text string) bool {
+	var upper, lower, digit bool
+	for _, r := range text {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
+
+// Returns the Shannon entropy of a string in bits per character
+func entropy(text string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range text {
+		counts[r]++
+	}
+	length := float64(len(text))
+	bits := 0.0
+	for _, count := range counts {
+		p := float64(count) / length
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// Report prints the findings. The suggest policy adds the command that creates a secret instead.
+func (s *Scanner) Report() {
+	if len(s.findings) == 0 {
+		return
+	}
+	for _, finding := range s.findings {
+		action := "looks like a credential, store it as a secret instead"
+		if finding.Blocked {
+			action = "looks like a credential and was withheld by the secret scan policy"
+		}
+		output.Warning("Variable %s in %s %s (%s)", finding.Name, finding.Location(), action, finding.Rule)
+	}
+	if s.policy != PolicySuggest {
+		return
+	}
+	data := pterm.TableData{{"Variable", "Location", "Pattern", "Suggested command"}}
+	for _, finding := range s.findings {
+		data = append(data, []string{finding.Name, finding.Location(), finding.Rule, finding.Suggestion()})
+	}
+	fmt.Println()
+	pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
+
+// Summarize adds the findings to a command summary
+func (s *Scanner) Summarize(summary *output.Summary) {
+	if s.policy == PolicyOff {
+		return
+	}
+	var items []string
+	for _, finding := range s.findings {
+		item := fmt.Sprintf("%s in %s (%s)", finding.Name, finding.Location(), finding.Rule)
+		if s.policy == PolicySuggest {
+			item += ": " + finding.Suggestion()
+		}
+		items = append(items, item)
+	}
+	summary.AddList("🔑", "Variables that look like credentials", "credentials", items)
+	if s.policy == PolicyBlock {
+		summary.Add("⛔", "Withheld by secret scan policy", "credentials_blocked", s.Blocked())
+	}
+}
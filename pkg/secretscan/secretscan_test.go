@@ -0,0 +1,75 @@
+package secretscan
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	// Credentials are assembled at run time so that the test file itself does not look like one
+	random := "Zq8Xv3Lm9Tk2Wp7Rj4Hn6Bd1Fs5Gy0Ca"
+	tests := []struct {
+		name     string
+		variable string
+		value    string
+		want     string
+	}{
+		{name: "plain value", variable: "ENVIRONMENT", value: "production"},
+		{name: "URL", variable: "API_URL", value: "https://api.example.com/v1/items?page=1"},
+		{name: "GitHub token", variable: "TOKEN", value: "ghp_" + strings.Repeat("a1B2", 9), want: RuleGitHubToken},
+		{name: "fine-grained GitHub token", variable: "TOKEN", value: "github_pat_" + strings.Repeat("a1B2_", 5), want: RuleGitHubToken},
+		{name: "AWS access key", variable: "KEY", value: "AKIA" + strings.Repeat("Z9", 8), want: RuleAWSAccessKey},
+		{name: "AWS secret key in an AWS variable", variable: "AWS_SECRET", value: strings.Repeat("aB3/", 10), want: RuleAWSSecretKey},
+		{name: "AWS secret key shape outside AWS variables", variable: "CHECKSUM", value: strings.Repeat("aB3/", 10)},
+		{name: "JWT", variable: "SESSION", value: "eyJ" + strings.Repeat("a", 12) + ".eyJ" + strings.Repeat("b", 12) + "." + strings.Repeat("c", 12), want: RuleJWT},
+		{name: "private key", variable: "DEPLOY_KEY", value: "-----BEGIN " + "OPENSSH PRIVATE KEY-----\n...", want: RulePrivateKey},
+		{name: "random string", variable: "VALUE", value: random, want: RuleHighEntropy},
+		{name: "random string inside a longer value", variable: "DSN", value: "user:" + random + "@db", want: RuleHighEntropy},
+		{name: "hex digest", variable: "SHA", value: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{name: "UUID", variable: "TENANT", value: "123e4567-e89b-12d3-a456-426614174000"},
+		{name: "long low-entropy string", variable: "PATTERN", value: strings.Repeat("Aa1", 12)},
+		{name: "random string too short", variable: "VALUE", value: random[:31]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.variable, tt.value); got != tt.want {
+				t.Fatalf("Detect(%q) = %q, want %q", tt.variable, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"aaaa", 0},
+		{"abab", 1},
+		{"abcdefghijklmnop", 4},
+		{"Zq8Xv3Lm9Tk2Wp7Rj4Hn6Bd1Fs5Gy0Ca", 5},
+	}
+	for _, tt := range tests {
+		if got := entropy(tt.text); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("entropy(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEntropyThreshold(t *testing.T) {
+	// 22 distinct characters carry log2(22) ≈ 4.46 bits, 23 carry log2(23) ≈ 4.52 bits
+	below := "Zq8Xv3Lm9Tk2Wp7Rj4Hn6B"
+	above := below + "d"
+	for _, tt := range []struct {
+		text string
+		want string
+	}{
+		{strings.Repeat(below, 2), ""},
+		{strings.Repeat(above, 2), RuleHighEntropy},
+	} {
+		if got := Detect("VALUE", tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q with entropy %.2f, want %q", tt.text, got, entropy(tt.text), tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	gosync "sync"
+	"syscall"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/pterm/pterm"
+)
+
+const (
+	ArchivedPolicySkip      = "skip"
+	ArchivedPolicyUnarchive = "unarchive"
+	ArchivedPolicyFail      = "fail"
+)
+
+// repoTarget is a target repository a sync writes variables or secrets to
+type repoTarget struct {
+	org  string
+	repo string
+}
+
+func (t repoTarget) location() string {
+	return t.org + "/" + t.repo
+}
+
+// Collects the distinct target repositories that variables, pruned variables, and secrets are
+// written to, in order of first appearance
+func repoTargets(records []variableRecord, candidates []pruneCandidate, secrets *secretPlan) []repoTarget {
+	var targets []repoTarget
+	seen := make(map[repoTarget]bool)
+	add := func(org, repo string) {
+		target := repoTarget{org, repo}
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	for _, record := range records {
+		if !record.isOrgLevel() {
+			add(record.TargetOrg, record.Scope)
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate.Scope != api.EntityTypeOrg {
+			add(candidate.TargetOrg, candidate.Scope)
+		}
+	}
+	if secrets != nil {
+		for _, record := range secrets.records {
+			if !record.isOrgLevel() {
+				add(record.TargetOrg, record.Scope)
+			}
+		}
+	}
+	return targets
+}
+
+// archivedRepos tracks the archived target repositories a sync touches. Repositories can be
+// restored from a signal handler while the sync is running, so the unarchived list is guarded.
+type archivedRepos struct {
+	policy     string
+	token      string
+	hostname   string
+	dryRun     bool
+	catalogs   map[string]*api.RepoCatalog
+	mu         gosync.Mutex
+	unarchived []string
+	failed     map[string]bool
+}
+
+func newArchivedRepos(policy string, catalogs map[string]*api.RepoCatalog, token, hostname string, dryRun bool) (*archivedRepos, error) {
+	switch policy {
+	case "":
+		policy = ArchivedPolicySkip
+	case ArchivedPolicySkip, ArchivedPolicyUnarchive, ArchivedPolicyFail:
+	default:
+		return nil, fmt.Errorf("unknown archived policy %q (expected skip, unarchive, or fail)", policy)
+	}
+	return &archivedRepos{
+		policy:   policy,
+		token:    token,
+		hostname: hostname,
+		dryRun:   dryRun,
+		catalogs: catalogs,
+		failed:   make(map[string]bool),
+	}, nil
+}
+
+// Reports whether a target repository is archived. Organization-level writes are never affected.
+func (a *archivedRepos) isArchived(org, repo string) bool {
+	if repo == "" || repo == api.EntityTypeOrg || a.catalogs[org] == nil {
+		return false
+	}
+	info, ok := a.catalogs[org].Lookup(repo)
+	return ok && info.Archived
+}
+
+// Refuses to start the sync under the fail policy when any target repository is archived
+func (a *archivedRepos) check(targets []repoTarget) error {
+	if a.policy != ArchivedPolicyFail {
+		return nil
+	}
+	var archived []string
+	for _, target := range targets {
+		if a.isArchived(target.org, target.repo) {
+			archived = append(archived, target.location())
+		}
+	}
+	if len(archived) == 0 {
+		return nil
+	}
+	sort.Strings(archived)
+	return fmt.Errorf("target repositories are archived: %s (use --archived-policy skip or unarchive)", strings.Join(archived, ", "))
+}
+
+// Reports whether a write to a target repository can go ahead, unarchiving an archived repository
+// first under the unarchive policy. It returns false when the write must be skipped, either by
+// policy or because the repository could not be unarchived.
+func (a *archivedRepos) writable(org, repo string) bool {
+	if !a.isArchived(org, repo) {
+		return true
+	}
+	location := repoTarget{org, repo}.location()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.policy != ArchivedPolicyUnarchive || a.failed[location] {
+		return false
+	}
+	for _, unarchived := range a.unarchived {
+		if unarchived == location {
+			return true
+		}
+	}
+
+	if a.dryRun {
+		pterm.Info.Printf("Would temporarily unarchive repository %s\n", location)
+	} else {
+		if err := api.SetRepositoryArchived(org, repo, false, a.token, a.hostname); err != nil {
+			pterm.Error.Printf("Failed to unarchive repository %s: %v\n", location, err)
+			a.failed[location] = true
+			return false
+		}
+		pterm.Info.Printf("Temporarily unarchived repository %s\n", location)
+	}
+	a.unarchived = append(a.unarchived, location)
+	return true
+}
+
+// Re-archives every repository that was unarchived during the sync, returning the number that
+// failed. Repositories are only re-archived once, so it is safe to call again.
+func (a *archivedRepos) restore() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	failed := 0
+	for _, location := range a.unarchived {
+		if a.dryRun {
+			pterm.Info.Printf("Would re-archive repository %s\n", location)
+			continue
+		}
+		org, repo, _ := strings.Cut(location, "/")
+		if err := api.SetRepositoryArchived(org, repo, true, a.token, a.hostname); err != nil {
+			pterm.Error.Printf("Failed to re-archive repository %s: %v\n", location, err)
+			failed++
+			continue
+		}
+		pterm.Success.Printf("Re-archived repository %s\n", location)
+	}
+	a.unarchived = nil
+	return failed
+}
+
+// Re-archives the unarchived repositories when the sync is interrupted, before the process exits.
+// The returned function stops watching for the interrupt.
+func (a *archivedRepos) restoreOnInterrupt() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			pterm.Warning.Println("Interrupted, re-archiving unarchived repositories...")
+			a.restore()
+			logging.Exit(130)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
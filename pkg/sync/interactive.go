@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/mona-actions/gh-migrate-variables/pkg/wizard"
+)
+
+// Lets the operator pick the target organization from those the token's user belongs to
+func selectTargetOrganization(token, hostname string) (string, error) {
+	organizations, err := api.FetchUserOrganizations(token, hostname)
+	if err != nil {
+		return "", err
+	}
+	return wizard.SelectOne("Target organization", organizations)
+}
+
+// Lets the operator narrow the records down to some source organizations, target locations, and
+// variables. It returns the records in the selected organizations and locations, which bound what
+// prune may delete, and the selected variables within them, which are the ones synced.
+func selectRecords(records []variableRecord) (scoped, selected []variableRecord, err error) {
+	// Source organizations, only asked for when the input file holds more than one
+	var sourceOrgs []string
+	seen := make(map[string]bool)
+	for _, record := range records {
+		if !seen[record.SourceOrg] {
+			seen[record.SourceOrg] = true
+			sourceOrgs = append(sourceOrgs, record.SourceOrg)
+		}
+	}
+	sort.Strings(sourceOrgs)
+	chosenOrgs, err := wizard.SelectMany("Source organizations to sync", sourceOrgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	records = filterRecords(records, chosenOrgs, func(r variableRecord) string { return r.SourceOrg })
+
+	// Target organizations and repositories
+	var locations []string
+	seen = make(map[string]bool)
+	for _, record := range records {
+		if label := locationLabel(record); !seen[label] {
+			seen[label] = true
+			locations = append(locations, label)
+		}
+	}
+	sort.Strings(locations)
+	chosenLocations, err := wizard.SelectMany("Organizations and repositories to sync", locations)
+	if err != nil {
+		return nil, nil, err
+	}
+	scoped = filterRecords(records, chosenLocations, locationLabel)
+
+	// Variables within the chosen locations
+	variables := make([]string, 0, len(scoped))
+	for _, record := range scoped {
+		variables = append(variables, variableLabel(record))
+	}
+	chosenVariables, err := wizard.SelectMany("Variables to sync", variables)
+	if err != nil {
+		return nil, nil, err
+	}
+	selected = filterRecords(scoped, chosenVariables, variableLabel)
+	return scoped, selected, nil
+}
+
+// Keeps the records whose label is one of the chosen options
+func filterRecords(records []variableRecord, chosen []string, label func(variableRecord) string) []variableRecord {
+	keep := make(map[string]bool, len(chosen))
+	for _, option := range chosen {
+		keep[option] = true
+	}
+	var filtered []variableRecord
+	for _, record := range records {
+		if keep[label(record)] {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// Names the target location of a record, marking organization-level variables
+func locationLabel(record variableRecord) string {
+	if record.isOrgLevel() {
+		return record.location() + " (organization)"
+	}
+	return record.location()
+}
+
+// Names a record within its target location
+func variableLabel(record variableRecord) string {
+	return record.location() + ": " + record.Name
+}
+
+// Prints the variables a sync will write and delete, grouped by target location, and asks the
+// operator to confirm it
+func confirmPlan(records []variableRecord, pruneCandidates []pruneCandidate, dryRun bool) (bool, error) {
+	groups := make(map[string][]string)
+	for _, record := range records {
+		line := "  + " + record.Name
+		if record.isOrgLevel() && record.Visibility != "" {
+			line += fmt.Sprintf(" (visibility: %s)", record.Visibility)
+		}
+		groups[locationLabel(record)] = append(groups[locationLabel(record)], line)
+	}
+	for _, candidate := range pruneCandidates {
+		label := candidate.location()
+		if candidate.Scope == api.EntityTypeOrg {
+			label += " (organization)"
+		}
+		groups[label] = append(groups[label], "  - "+candidate.Name)
+	}
+	locations := make([]string, 0, len(groups))
+	for location := range groups {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	output.Message("📋", "Sync plan:")
+	for _, location := range locations {
+		fmt.Printf("\n  # %s\n", location)
+		for _, line := range groups[location] {
+			fmt.Println(line)
+		}
+	}
+	fmt.Printf("\nPlan: %d to sync, %d to delete across %d locations.\n\n", len(records), len(pruneCandidates), len(locations))
+
+	if dryRun {
+		return true, nil
+	}
+	return wizard.Confirm("Sync these variables?")
+}
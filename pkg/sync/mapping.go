@@ -0,0 +1,364 @@
+package sync
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/pterm/pterm"
+)
+
+const (
+	CollisionPolicyPrefix    = "prefix"
+	CollisionPolicyFirstWins = "first-wins"
+	CollisionPolicyFail      = "fail"
+	CollisionPolicyDemote    = "demote"
+)
+
+var invalidVariableNameChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// orgMapping routes source organizations, and optionally individual repositories, to target organizations
+type orgMapping struct {
+	defaultTarget string
+	orgs          map[string]string
+	repos         map[string]map[string]string
+}
+
+// Loads a mapping CSV file with SourceOrganization,SourceRepository,TargetOrganization columns.
+// An empty or "*" SourceRepository maps the whole source organization.
+func loadOrgMapping(path, defaultTarget string) (*orgMapping, error) {
+	mapping := &orgMapping{
+		defaultTarget: defaultTarget,
+		orgs:          make(map[string]string),
+		repos:         make(map[string]map[string]string),
+	}
+	if path == "" {
+		return mapping, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open mapping file %s: %v", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read mapping file %s: %v", path, err)
+	}
+
+	// Skip header row and load each route
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("mapping file %s line %d: expected SourceOrganization,SourceRepository,TargetOrganization", path, i+1)
+		}
+		sourceOrg := strings.TrimSpace(record[0])
+		sourceRepo := strings.TrimSpace(record[1])
+		targetOrg := strings.TrimSpace(record[2])
+		if sourceOrg == "" || targetOrg == "" {
+			return nil, fmt.Errorf("mapping file %s line %d: source and target organization are required", path, i+1)
+		}
+
+		if sourceRepo == "" || sourceRepo == "*" {
+			mapping.orgs[sourceOrg] = targetOrg
+			continue
+		}
+		if mapping.repos[sourceOrg] == nil {
+			mapping.repos[sourceOrg] = make(map[string]string)
+		}
+		mapping.repos[sourceOrg][sourceRepo] = targetOrg
+	}
+
+	return mapping, nil
+}
+
+// Returns the target organization for a source organization and optional repository
+func (m *orgMapping) targetFor(sourceOrg, repo string) string {
+	if repo != "" {
+		if target, ok := m.repos[sourceOrg][repo]; ok {
+			return target
+		}
+	}
+	if target, ok := m.orgs[sourceOrg]; ok {
+		return target
+	}
+	return m.defaultTarget
+}
+
+// Returns every target organization that receives repositories or variables from a source organization
+func (m *orgMapping) targetsForOrg(sourceOrg string) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	add := func(target string) {
+		if target != "" && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+
+	add(m.targetFor(sourceOrg, ""))
+	for _, target := range m.repos[sourceOrg] {
+		add(target)
+	}
+	return targets
+}
+
+// Routes each record to its target organization. Organization-level variables are copied to
+// every target organization that receives repositories from their source organization.
+func routeRecords(records []variableRecord, mapping *orgMapping) ([]variableRecord, error) {
+	var routed []variableRecord
+	for _, record := range records {
+		if record.isOrgLevel() {
+			targets := mapping.targetsForOrg(record.SourceOrg)
+			if len(targets) == 0 {
+				return nil, fmt.Errorf("no target organization for organization variable %s from %q", record.Name, record.SourceOrg)
+			}
+			for _, target := range targets {
+				record.TargetOrg = target
+				routed = append(routed, record)
+			}
+			continue
+		}
+
+		record.TargetOrg = mapping.targetFor(record.SourceOrg, record.Scope)
+		if record.TargetOrg == "" {
+			return nil, fmt.Errorf("no target organization for repository %s from %q", record.Scope, record.SourceOrg)
+		}
+		routed = append(routed, record)
+	}
+	return routed, nil
+}
+
+// Checks that a collision policy is known, so that a typo is caught before any collision happens
+func validateCollisionPolicy(policy string) error {
+	switch policy {
+	case CollisionPolicyPrefix, CollisionPolicyFirstWins, CollisionPolicyFail, CollisionPolicyDemote:
+		return nil
+	}
+	return fmt.Errorf("unknown collision policy %q (expected %s, %s, %s, or %s)",
+		policy, CollisionPolicyPrefix, CollisionPolicyFirstWins, CollisionPolicyFail, CollisionPolicyDemote)
+}
+
+// Resolves organization-level variables from different source organizations that land on
+// the same name in the same target organization. Returns the records to sync and the
+// number of records skipped by the policy. The demote policy reads the repository catalog of
+// the target organization to find the repositories that inherited the variable.
+func resolveCollisions(records []variableRecord, mapping *orgMapping, policy, token, hostname string) ([]variableRecord, int, error) {
+	type collisionKey struct{ targetOrg, name string }
+
+	// Group organization-level variables by target organization and name
+	groups := make(map[collisionKey][]int)
+	for i, record := range records {
+		if record.isOrgLevel() {
+			key := collisionKey{record.TargetOrg, record.Name}
+			groups[key] = append(groups[key], i)
+		}
+	}
+
+	drop := make(map[int]bool)
+	colliding := make(map[int]bool)
+	var collisions []string
+	for key, indexes := range groups {
+		if len(indexes) < 2 {
+			continue
+		}
+		// Identical definitions are not a conflict, keep only the first one
+		first := records[indexes[0]]
+		conflict := false
+		for _, i := range indexes[1:] {
+			if records[i].Value != first.Value || records[i].Visibility != first.Visibility {
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			for _, i := range indexes[1:] {
+				drop[i] = true
+			}
+			continue
+		}
+
+		var sources []string
+		for _, i := range indexes {
+			colliding[i] = true
+			sources = append(sources, records[i].SourceOrg)
+		}
+		collisions = append(collisions, fmt.Sprintf("%s in %s (from %s)", key.name, key.targetOrg, strings.Join(sources, ", ")))
+	}
+
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		if err := validateCollisionPolicy(policy); err != nil {
+			return nil, 0, err
+		}
+		if policy == CollisionPolicyFail {
+			return nil, 0, fmt.Errorf("organization variable collisions found: %s", strings.Join(collisions, "; "))
+		}
+		for _, collision := range collisions {
+			pterm.Warning.Printf("Variable collision (%s): %s\n", policy, collision)
+		}
+	}
+
+	var resolved []variableRecord
+	skipped := 0
+	firstSeen := make(map[collisionKey]bool)
+	for i, record := range records {
+		if drop[i] {
+			continue
+		}
+		if !colliding[i] {
+			resolved = append(resolved, record)
+			continue
+		}
+
+		switch policy {
+		case CollisionPolicyPrefix:
+			record.Name = prefixedVariableName(record.SourceOrg, record.Name)
+			resolved = append(resolved, record)
+		case CollisionPolicyFirstWins:
+			key := collisionKey{record.TargetOrg, record.Name}
+			if firstSeen[key] {
+				pterm.Warning.Printf("Skipping variable %s from %s: already defined in %s by an earlier organization\n",
+					record.Name, record.SourceOrg, record.TargetOrg)
+				skipped++
+				continue
+			}
+			firstSeen[key] = true
+			resolved = append(resolved, record)
+		case CollisionPolicyDemote:
+			catalog, err := api.FetchRepoCatalog(record.TargetOrg, token, hostname)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to fetch repositories for %s: %w", record.TargetOrg, err)
+			}
+			demoted := demoteRecord(record, records, mapping, catalog)
+			if len(demoted) == 0 {
+				pterm.Warning.Printf("Skipping variable %s from %s: no repositories found to demote it to\n", record.Name, record.SourceOrg)
+				skipped++
+				continue
+			}
+			resolved = append(resolved, demoted...)
+		}
+	}
+
+	return resolved, skipped, nil
+}
+
+// Builds repository-level copies of an organization variable for every repository of the target
+// organization that inherited it in the source organization. A repository belongs to the source
+// organization when it is mapped from it, has repository variables from it in the input file,
+// or, when neither applies to any source organization, when the source organization is the
+// only one mapped as a whole to the target. The variable's visibility limits the copies to
+// non-public repositories for private, and to its selected repositories for selected.
+// Repositories that already define a variable with the same name keep their own value.
+func demoteRecord(record variableRecord, records []variableRecord, mapping *orgMapping, catalog *api.RepoCatalog) []variableRecord {
+	// Source organizations each target repository is known to come from, by lower-cased name
+	owners := make(map[string]map[string]bool)
+	repos := make(map[string]string)
+	claim := func(repo, sourceOrg string) {
+		key := strings.ToLower(repo)
+		if owners[key] == nil {
+			owners[key] = make(map[string]bool)
+		}
+		owners[key][sourceOrg] = true
+		if _, ok := repos[key]; !ok {
+			repos[key] = repo
+		}
+	}
+
+	defined := make(map[string]bool)
+	wholeOrgSources := make(map[string]bool)
+	for _, other := range records {
+		if other.TargetOrg != record.TargetOrg {
+			continue
+		}
+		if mapping.targetFor(other.SourceOrg, "") == record.TargetOrg {
+			wholeOrgSources[other.SourceOrg] = true
+		}
+		if other.isOrgLevel() {
+			continue
+		}
+		claim(other.Scope, other.SourceOrg)
+		if other.Name == record.Name {
+			defined[strings.ToLower(other.Scope)] = true
+		}
+	}
+	for sourceOrg, routes := range mapping.repos {
+		for repo, target := range routes {
+			if target == record.TargetOrg {
+				claim(repo, sourceOrg)
+			}
+		}
+	}
+
+	// Repositories nobody claims belong to the source organization only when it is the sole one mapped as a whole
+	var unclaimed int
+	for _, name := range catalog.Names() {
+		key := strings.ToLower(name)
+		if owners[key] != nil {
+			continue
+		}
+		if len(wholeOrgSources) == 1 && wholeOrgSources[record.SourceOrg] {
+			claim(name, record.SourceOrg)
+		} else if wholeOrgSources[record.SourceOrg] {
+			unclaimed++
+		}
+	}
+	if unclaimed > 0 {
+		pterm.Warning.Printf("Not demoting variable %s from %s to %d repositories in %s: their source organization is unknown, map them in the mapping file\n",
+			record.Name, record.SourceOrg, unclaimed, record.TargetOrg)
+	}
+
+	selected := make(map[string]bool)
+	for _, repo := range record.SelectedRepos {
+		selected[strings.ToLower(repo)] = true
+	}
+	if record.Visibility == "selected" && len(record.SelectedRepos) == 0 {
+		pterm.Warning.Printf("Not demoting variable %s from %s: the input file does not list its selected repositories, export it again to include them\n",
+			record.Name, record.SourceOrg)
+	}
+
+	keys := make([]string, 0, len(repos))
+	for key := range repos {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var demoted []variableRecord
+	for _, key := range keys {
+		if !owners[key][record.SourceOrg] || defined[key] {
+			continue
+		}
+		switch record.Visibility {
+		case "private":
+			// Repositories that do not exist yet keep the variable, their visibility is unknown
+			if repo, ok := catalog.Lookup(key); ok && repo.Visibility == "public" {
+				continue
+			}
+		case "selected":
+			if !selected[key] {
+				continue
+			}
+		}
+		repoRecord := record
+		repoRecord.Scope = repos[key]
+		repoRecord.Visibility = ""
+		repoRecord.SelectedRepos = nil
+		demoted = append(demoted, repoRecord)
+	}
+	return demoted
+}
+
+// Prefixes a variable name with its sanitized source organization name
+func prefixedVariableName(sourceOrg, name string) string {
+	prefix := invalidVariableNameChars.ReplaceAllString(strings.ToUpper(sourceOrg), "_")
+	return prefix + "_" + name
+}
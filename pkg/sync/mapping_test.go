@@ -0,0 +1,168 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+)
+
+// Returns the location and name of each record, in order
+func recordNames(records []variableRecord) []string {
+	var names []string
+	for _, record := range records {
+		names = append(names, record.location()+" "+record.Name)
+	}
+	return names
+}
+
+func TestResolveCollisions(t *testing.T) {
+	colliding := []variableRecord{
+		{Name: "ENV", Value: "a", Scope: api.EntityTypeOrg, SourceOrg: "alpha", TargetOrg: "target"},
+		{Name: "ENV", Value: "b", Scope: api.EntityTypeOrg, SourceOrg: "beta", TargetOrg: "target"},
+		{Name: "URL", Value: "x", Scope: "app", SourceOrg: "alpha", TargetOrg: "target"},
+	}
+	tests := []struct {
+		name        string
+		records     []variableRecord
+		policy      string
+		want        []string
+		wantSkipped int
+		wantErr     bool
+	}{
+		{
+			name:    "identical definitions are kept once",
+			records: []variableRecord{colliding[0], colliding[0], colliding[2]},
+			policy:  CollisionPolicyFail,
+			want:    []string{"target ENV", "target/app URL"},
+		},
+		{
+			name: "same name in different target organizations does not collide",
+			records: []variableRecord{
+				colliding[0],
+				{Name: "ENV", Value: "b", Scope: api.EntityTypeOrg, SourceOrg: "beta", TargetOrg: "other"},
+			},
+			policy: CollisionPolicyFail,
+			want:   []string{"target ENV", "other ENV"},
+		},
+		{
+			name:    "prefix",
+			records: colliding,
+			policy:  CollisionPolicyPrefix,
+			want:    []string{"target ALPHA_ENV", "target BETA_ENV", "target/app URL"},
+		},
+		{
+			name:        "first wins",
+			records:     colliding,
+			policy:      CollisionPolicyFirstWins,
+			want:        []string{"target ENV", "target/app URL"},
+			wantSkipped: 1,
+		},
+		{name: "fail", records: colliding, policy: CollisionPolicyFail, wantErr: true},
+		{name: "unknown policy", records: colliding, policy: "merge", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, skipped, err := resolveCollisions(tt.records, &orgMapping{}, tt.policy, "", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveCollisions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := recordNames(resolved); !reflect.DeepEqual(got, tt.want) || skipped != tt.wantSkipped {
+				t.Fatalf("got %v skipped %d, want %v skipped %d", got, skipped, tt.want, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestDemoteRecord(t *testing.T) {
+	catalog := api.NewRepoCatalog("target", "", []api.Repository{
+		{Name: "App", Visibility: "private"},
+		{Name: "docs", Visibility: "public"},
+		{Name: "tools", Visibility: "internal"},
+	})
+	variable := variableRecord{Name: "ENV", Value: "a", Scope: api.EntityTypeOrg, SourceOrg: "alpha", TargetOrg: "target"}
+	withVisibility := func(visibility string, selected ...string) variableRecord {
+		record := variable
+		record.Visibility = visibility
+		record.SelectedRepos = selected
+		return record
+	}
+	orgsMapping := func(orgs map[string]string) *orgMapping {
+		return &orgMapping{orgs: orgs, repos: map[string]map[string]string{}}
+	}
+
+	tests := []struct {
+		name    string
+		record  variableRecord
+		records []variableRecord
+		mapping *orgMapping
+		want    []string
+	}{
+		{
+			name:    "sole organization mapped as a whole owns every repository",
+			record:  withVisibility("all"),
+			mapping: orgsMapping(map[string]string{"alpha": "target"}),
+			want:    []string{"target/App ENV", "target/docs ENV", "target/tools ENV"},
+		},
+		{
+			name:    "private skips public repositories",
+			record:  withVisibility("private"),
+			mapping: orgsMapping(map[string]string{"alpha": "target"}),
+			want:    []string{"target/App ENV", "target/tools ENV"},
+		},
+		{
+			name:    "selected keeps only selected repositories",
+			record:  withVisibility("selected", "app"),
+			mapping: orgsMapping(map[string]string{"alpha": "target"}),
+			want:    []string{"target/App ENV"},
+		},
+		{
+			name:    "selected without repositories demotes nothing",
+			record:  withVisibility("selected"),
+			mapping: orgsMapping(map[string]string{"alpha": "target"}),
+		},
+		{
+			name:   "repository variable of the same name is kept",
+			record: withVisibility("all"),
+			records: []variableRecord{
+				{Name: "ENV", Value: "own", Scope: "App", SourceOrg: "alpha", TargetOrg: "target"},
+			},
+			mapping: orgsMapping(map[string]string{"alpha": "target"}),
+			want:    []string{"target/docs ENV", "target/tools ENV"},
+		},
+		{
+			name:   "repositories are claimed by their variables when several organizations merge",
+			record: withVisibility("all"),
+			records: []variableRecord{
+				{Name: "URL", Scope: "App", SourceOrg: "alpha", TargetOrg: "target"},
+				{Name: "URL", Scope: "docs", SourceOrg: "beta", TargetOrg: "target"},
+			},
+			mapping: orgsMapping(map[string]string{"alpha": "target", "beta": "target"}),
+			want:    []string{"target/App ENV"},
+		},
+		{
+			name:   "repositories routed from another organization are skipped",
+			record: withVisibility("all"),
+			mapping: &orgMapping{
+				defaultTarget: "target",
+				orgs:          map[string]string{},
+				repos:         map[string]map[string]string{"alpha": {"tools": "target"}, "beta": {"App": "target"}},
+			},
+			want: []string{"target/docs ENV", "target/tools ENV"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := append([]variableRecord{tt.record}, tt.records...)
+			demoted := demoteRecord(tt.record, records, tt.mapping, catalog)
+			if got := recordNames(demoted); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for _, record := range demoted {
+				if record.Visibility != "" || record.SelectedRepos != nil {
+					t.Fatalf("demoted record %s keeps organization visibility %q", record.location(), record.Visibility)
+				}
+			}
+		})
+	}
+}
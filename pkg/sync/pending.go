@@ -0,0 +1,370 @@
+package sync
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/journal"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/mona-actions/gh-migrate-variables/pkg/preflight"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+const defaultPollInterval = 30 * time.Second
+
+// pendingQueue holds variables for target repositories that do not exist yet, so that a later
+// sync --pending run can replay them once the repositories have been migrated
+type pendingQueue struct {
+	path    string
+	records []variableRecord
+}
+
+// Returns the pending queue file for a sync, defaulting to <target-organization>_pending.csv
+func pendingQueuePath(targetOrg string) string {
+	if path := viper.GetString("pending-file"); path != "" {
+		return path
+	}
+	if targetOrg == "" {
+		return "pending.csv"
+	}
+	return targetOrg + "_pending.csv"
+}
+
+// Loads a pending queue file. A missing file is an empty queue.
+func loadPendingQueue(path string) (*pendingQueue, error) {
+	queue := &pendingQueue{path: path}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return queue, nil
+		}
+		return nil, fmt.Errorf("cannot open pending file %s: %v", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read pending file %s: %v", path, err)
+	}
+
+	// Skip header row and load each queued variable
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		if len(row) < 6 {
+			return nil, fmt.Errorf("pending file %s line %d: expected Name,Value,Scope,Visibility,SourceOrganization,TargetOrganization", path, i+1)
+		}
+		queue.records = append(queue.records, variableRecord{
+			Name:       row[0],
+			Value:      row[1],
+			Scope:      row[2],
+			Visibility: row[3],
+			SourceOrg:  row[4],
+			TargetOrg:  row[5],
+		})
+	}
+	return queue, nil
+}
+
+// Adds a variable to the queue, replacing an earlier entry for the same repository and name
+func (q *pendingQueue) add(record variableRecord) {
+	for i, queued := range q.records {
+		if queued.location() == record.location() && queued.Name == record.Name {
+			q.records[i] = record
+			return
+		}
+	}
+	q.records = append(q.records, record)
+}
+
+// Writes the queue back to disk, removing the file once the queue is empty
+func (q *pendingQueue) save() error {
+	if len(q.records) == 0 {
+		if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove pending file %s: %w", q.path, err)
+		}
+		return nil
+	}
+
+	// The queue holds variable values, so it is only readable by the current user
+	file, err := os.OpenFile(q.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot create pending file %s: %w", q.path, err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"Name", "Value", "Scope", "Visibility", "SourceOrganization", "TargetOrganization"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, record := range q.records {
+		if err := writer.Write([]string{record.Name, record.Value, record.Scope, record.Visibility, record.SourceOrg, record.TargetOrg}); err != nil {
+			return fmt.Errorf("failed to write pending variable to CSV: %w", err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Groups the queued variables by target repository, in a stable order
+func (q *pendingQueue) byRepository() ([]string, map[string][]variableRecord) {
+	groups := make(map[string][]variableRecord)
+	for _, record := range q.records {
+		groups[record.location()] = append(groups[record.location()], record)
+	}
+	locations := make([]string, 0, len(groups))
+	for location := range groups {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	return locations, groups
+}
+
+// SyncPending replays the variables queued for target repositories that did not exist during an
+// earlier sync. With a wait timeout it polls for the repositories to appear before giving up.
+func SyncPending() error {
+	start := time.Now()
+
+	hostname := viper.GetString("target-hostname")
+	targetOrg := viper.GetString("target-organization")
+	targetToken := viper.GetString("target-token")
+	dryRun := viper.GetBool("GHMV_DRY_RUN")
+
+	if targetToken == "" {
+		return fmt.Errorf("missing required parameters: target token")
+	}
+
+	// Check that the target instance supports repository variables before any work starts
+	if _, err := preflight.Check(targetToken, hostname, []string{api.FeatureActionsVariables}, nil); err != nil {
+		return err
+	}
+
+	waitTimeout, err := parseDurationSetting("wait-timeout", 0)
+	if err != nil {
+		return err
+	}
+	pollInterval, err := parseDurationSetting("poll-interval", defaultPollInterval)
+	if err != nil {
+		return err
+	}
+
+	queue, err := loadPendingQueue(pendingQueuePath(targetOrg))
+	if err != nil {
+		return err
+	}
+	if len(queue.records) == 0 {
+		pterm.Info.Printf("No pending variables in %s.\n", queue.path)
+		return nil
+	}
+
+	if err := preflight.CheckAccess(preflight.Endpoint{
+		Label:         "Target",
+		Token:         targetToken,
+		Hostname:      hostname,
+		Organizations: targetOrganizations(queue.records),
+		Access:        preflight.AccessWrite,
+	}); err != nil {
+		return err
+	}
+
+	var stats struct {
+		total     int
+		succeeded int
+		updated   int
+		unchanged int
+		failed    int
+		archived  int
+	}
+	stats.total = len(queue.records)
+
+	var journalWriter *journal.Writer
+	journalFile := viper.GetString("journal-file")
+	if journalFile == "" {
+		journalFile = journal.DefaultPath(targetOrg, start)
+	}
+	if !dryRun {
+		journalWriter, err = journal.Create(journalFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Repositories found while polling, so that archived repositories are handled as in a sync
+	catalogs := make(map[string]*api.RepoCatalog)
+	found := make(map[string][]api.Repository)
+	archived, err := newArchivedRepos(viper.GetString("archived-policy"), catalogs, targetToken, hostname, dryRun)
+	if err != nil {
+		return err
+	}
+	// Unarchived repositories are archived again even when the replay stops early or is interrupted
+	defer archived.restore()
+	stopWatching := archived.restoreOnInterrupt()
+	defer stopWatching()
+
+	locations, groups := queue.byRepository()
+	pterm.Info.Printf("Replaying %d pending variables for %d repositories from %s\n", len(queue.records), len(locations), queue.path)
+	output.Group("Replaying pending variables")
+	progress := output.StartProgress("Replaying pending variables", len(queue.records))
+
+	deadline := start.Add(waitTimeout)
+	var remaining []variableRecord
+	for {
+		var waiting []string
+		for _, location := range locations {
+			records := groups[location]
+			org, repoName := records[0].TargetOrg, records[0].Scope
+			// The repository catalog and its disk cache never see repositories created after they
+			// were built, so every poll asks GitHub directly
+			repo, err := api.FetchRepository(org, repoName, targetToken, hostname)
+			if err != nil {
+				progress.Stop()
+				return err
+			}
+			if repo == nil {
+				waiting = append(waiting, location)
+				continue
+			}
+			found[org] = append(found[org], *repo)
+			catalogs[org] = api.NewRepoCatalog(org, hostname, found[org])
+
+			// Archived repositories are read-only, so their variables stay queued unless the policy
+			// unarchives them
+			if archived.isArchived(org, repoName) {
+				if archived.policy == ArchivedPolicyFail {
+					output.Error("Repository %s is archived, its %d pending variables remain queued (use --archived-policy skip or unarchive)", location, len(records))
+					stats.failed += len(records)
+					remaining = append(remaining, records...)
+					for range records {
+						progress.Failed()
+					}
+					continue
+				}
+				if !archived.writable(org, repoName) {
+					output.Detail(pterm.Warning, "Skipping %d pending variables: repository %s is archived\n", len(records), location)
+					stats.archived += len(records)
+					remaining = append(remaining, records...)
+					for range records {
+						progress.Skipped()
+					}
+					continue
+				}
+			}
+
+			output.Detail(pterm.Info, "Repository %s exists, syncing %d pending variables\n", location, len(records))
+			for _, record := range records {
+				outcome, err := syncRecord(record, targetToken, hostname, journalWriter, dryRun)
+				switch {
+				case err != nil:
+					output.Error("Error syncing repository variable %s in %s: %v", record.Name, location, err)
+					outcome = "failed"
+					stats.failed++
+					progress.Failed()
+					// Failed variables stay queued so that the next run retries them
+					remaining = append(remaining, record)
+				case outcome == outcomeCreated:
+					output.Detail(pterm.Success, "Added repository variable: %s in %s\n", record.Name, location)
+					stats.succeeded++
+					progress.Succeeded()
+				case outcome == outcomeUpdated:
+					output.Detail(pterm.Success, "Updated repository variable: %s in %s\n", record.Name, location)
+					stats.updated++
+					progress.Succeeded()
+				case outcome == outcomeUnchanged:
+					output.Detail(pterm.Info, "Unchanged repository variable: %s in %s\n", record.Name, location)
+					stats.unchanged++
+					progress.Succeeded()
+				}
+				output.Event("variable", map[string]any{"name": record.Name, "location": location, "outcome": outcome, "dry_run": dryRun})
+			}
+		}
+		locations = waiting
+
+		if len(locations) == 0 || !time.Now().Add(pollInterval).Before(deadline) {
+			break
+		}
+		pterm.Info.Printf("Waiting for %d repositories to appear, checking again in %v...\n", len(locations), pollInterval)
+		time.Sleep(pollInterval)
+	}
+
+	for _, location := range locations {
+		pterm.Warning.Printf("Repository %s still does not exist, its variables remain queued\n", location)
+		remaining = append(remaining, groups[location]...)
+		for range groups[location] {
+			progress.Skipped()
+		}
+	}
+	progress.Stop()
+	stats.failed += archived.restore()
+
+	journalEntries := 0
+	if journalWriter != nil {
+		journalEntries = journalWriter.Entries()
+		if err := journalWriter.Close(); err != nil {
+			pterm.Warning.Printf("Failed to close journal file %s: %v\n", journalFile, err)
+		}
+	}
+
+	if !dryRun {
+		queue.records = remaining
+		if err := queue.save(); err != nil {
+			return err
+		}
+	}
+
+	summary := output.NewSummary("sync-pending", "Pending Sync Summary")
+	summary.Add("", "Total pending variables", "total", stats.total)
+	summary.Add("✅", "Successfully created", "created", stats.succeeded)
+	summary.Add("🔄", "Updated", "updated", stats.updated)
+	summary.Add("⏸️ ", "Unchanged", "unchanged", stats.unchanged)
+	summary.Add("❌", "Failed", "failed", stats.failed)
+	if stats.archived > 0 {
+		summary.Add("🗄️ ", "Skipped (archived repository)", "archived", stats.archived)
+	}
+	summary.Add("⏳", "Still pending", "pending", len(remaining))
+	if len(remaining) > 0 {
+		summary.Add("📁", "Pending file", "pending_file", queue.path)
+	}
+	if journalEntries > 0 {
+		summary.Add("📒", "Journal file", "journal_file", journalFile)
+	}
+	summary.Add("🕐", "Total time", "duration", time.Since(start).Round(time.Second).String())
+
+	if stats.failed > 0 {
+		summary.Print(output.StatusFailed)
+		output.Failed(fmt.Sprintf("pending sync completed with %d failed variables", stats.failed))
+		logging.Exit(1)
+	}
+
+	if dryRun {
+		summary.Print(output.StatusDryRun)
+		output.Message("🔍", "Dry run: no changes were made.")
+		return nil
+	}
+
+	summary.Print(output.StatusSuccess)
+	output.Message("✅", "Pending sync completed successfully!")
+	return nil
+}
+
+// Parses a duration setting, returning the fallback when it is not set
+func parseDurationSetting(name string, fallback time.Duration) (time.Duration, error) {
+	value := viper.GetString(name)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --%s %q: %w", name, value, err)
+	}
+	return duration, nil
+}
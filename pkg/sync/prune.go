@@ -0,0 +1,152 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/pkg/journal"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+)
+
+// pruneCandidate is a target variable that is absent from the input file
+type pruneCandidate struct {
+	TargetOrg  string
+	Scope      string
+	Name       string
+	Value      string
+	Visibility string
+}
+
+func (c pruneCandidate) location() string {
+	if c.Scope == api.EntityTypeOrg {
+		return c.TargetOrg
+	}
+	return c.TargetOrg + "/" + c.Scope
+}
+
+// scopeKey is a target organization and a repository, or the organization itself
+type scopeKey struct{ targetOrg, scope string }
+
+// Collects the desired variable names for every scope in the input file. GitHub stores variable
+// names upper-cased, so the names are upper-cased too.
+func desiredVariables(records []variableRecord) map[scopeKey]map[string]bool {
+	desired := make(map[scopeKey]map[string]bool)
+	for _, record := range records {
+		key := scopeKey{record.TargetOrg, record.Scope}
+		if desired[key] == nil {
+			desired[key] = make(map[string]bool)
+		}
+		desired[key][strings.ToUpper(record.Name)] = true
+	}
+	return desired
+}
+
+// Finds target variables that are not in the input file. Only the scopes present in the
+// input file are inspected, so organizations and repositories it does not mention are never touched.
+func findPruneCandidates(records []variableRecord, token, hostname string) ([]pruneCandidate, error) {
+	desired := desiredVariables(records)
+	scopes := make([]scopeKey, 0, len(desired))
+	for key := range desired {
+		scopes = append(scopes, key)
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		if scopes[i].targetOrg != scopes[j].targetOrg {
+			return scopes[i].targetOrg < scopes[j].targetOrg
+		}
+		return scopes[i].scope < scopes[j].scope
+	})
+
+	var candidates []pruneCandidate
+	for _, key := range scopes {
+		var existing []map[string]string
+		var err error
+		if key.scope == api.EntityTypeOrg {
+			existing, err = api.FetchOrgVariables(key.targetOrg, token, hostname)
+		} else {
+			existing, err = api.FetchRepoVariables(key.targetOrg, key.scope, token, hostname)
+			// A repository missing from the target has nothing to prune
+			if api.IsNotFound(err) {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list variables in %s: %w", key.targetOrg+"/"+key.scope, err)
+		}
+
+		candidates = append(candidates, undesiredVariables(key.targetOrg, key.scope, existing, desired[key])...)
+	}
+
+	return candidates, nil
+}
+
+// Returns the existing variables of a scope whose names are not desired. Desired names are
+// upper-cased, and existing names are compared case-insensitively.
+func undesiredVariables(targetOrg, scope string, existing []map[string]string, desired map[string]bool) []pruneCandidate {
+	var candidates []pruneCandidate
+	for _, variable := range existing {
+		if desired[strings.ToUpper(variable["Name"])] {
+			continue
+		}
+		candidates = append(candidates, pruneCandidate{
+			TargetOrg:  targetOrg,
+			Scope:      scope,
+			Name:       variable["Name"],
+			Value:      variable["Value"],
+			Visibility: variable["Visibility"],
+		})
+	}
+	return candidates
+}
+
+// Asks the operator to confirm the deletion of the prune candidates
+func confirmPrune(candidates []pruneCandidate) (bool, error) {
+	output.Message("🗑️ ", fmt.Sprintf("The following %d variables are not in the input file and will be deleted:", len(candidates)))
+	for _, candidate := range candidates {
+		fmt.Printf("  - %s %s\n", candidate.location(), candidate.Name)
+	}
+	fmt.Println()
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("prune requires confirmation, re-run with --yes to delete without prompting")
+	}
+	return pterm.DefaultInteractiveConfirm.Show("Delete these variables?")
+}
+
+// Deletes a prune candidate from the target and records the deletion in the journal
+func pruneVariable(candidate pruneCandidate, token, hostname string, journalWriter *journal.Writer) error {
+	var err error
+	entry := journal.Entry{
+		Action:             journal.ActionDeleted,
+		Hostname:           hostname,
+		Organization:       candidate.TargetOrg,
+		Name:               candidate.Name,
+		PreviousValue:      candidate.Value,
+		PreviousVisibility: candidate.Visibility,
+	}
+	if candidate.Scope == api.EntityTypeOrg {
+		// The selected repositories are journaled so that a rollback can restore them
+		if candidate.Visibility == "selected" {
+			entry.PreviousSelectedRepos, err = api.FetchSelectedReposForOrgVariable(candidate.TargetOrg, candidate.Name, token, hostname)
+			if err != nil {
+				return err
+			}
+		}
+		err = api.DeleteOrgVariable(candidate.TargetOrg, candidate.Name, token, hostname)
+	} else {
+		entry.Repository = candidate.Scope
+		err = api.DeleteRepoVariable(candidate.TargetOrg, candidate.Scope, candidate.Name, token, hostname)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := journalWriter.Record(entry); err != nil {
+		return fmt.Errorf("variable was deleted but could not be journaled: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUndesiredVariables(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  []variableRecord
+		existing []string
+		want     []string
+	}{
+		{
+			name:     "lower-case name in the file matches the upper-cased target variable",
+			records:  []variableRecord{{Name: "foo", Scope: "repo", TargetOrg: "org"}},
+			existing: []string{"FOO"},
+		},
+		{
+			name:     "mixed case on both sides",
+			records:  []variableRecord{{Name: "Api_Url", Scope: "repo", TargetOrg: "org"}},
+			existing: []string{"api_URL"},
+		},
+		{
+			name:     "variable missing from the file is pruned",
+			records:  []variableRecord{{Name: "foo", Scope: "repo", TargetOrg: "org"}},
+			existing: []string{"FOO", "BAR"},
+			want:     []string{"BAR"},
+		},
+		{
+			name: "names of other scopes do not count",
+			records: []variableRecord{
+				{Name: "foo", Scope: "repo", TargetOrg: "org"},
+				{Name: "bar", Scope: "other", TargetOrg: "org"},
+			},
+			existing: []string{"FOO", "BAR"},
+			want:     []string{"BAR"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var existing []map[string]string
+			for _, name := range tt.existing {
+				existing = append(existing, map[string]string{"Name": name})
+			}
+			desired := desiredVariables(tt.records)
+
+			var got []string
+			for _, candidate := range undesiredVariables("org", "repo", existing, desired[scopeKey{"org", "repo"}]) {
+				got = append(got, candidate.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("pruned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
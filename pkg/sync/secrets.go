@@ -0,0 +1,288 @@
+package sync
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/pterm/pterm"
+)
+
+// Prefix of the environment variables secret values can be supplied through, e.g. GHMV_SECRET_DEPLOY_KEY.
+// A store-specific variable such as GHMV_DEPENDABOT_SECRET_DEPLOY_KEY takes precedence.
+const secretValueEnvPrefix = "GHMV_SECRET_"
+
+// secretRecord is a single secret read from an exported secrets inventory and routed to a target organization
+type secretRecord struct {
+	Store         string
+	Name          string
+	Scope         string
+	Environment   string
+	Visibility    string
+	SelectedRepos []string
+	SourceOrg     string
+	TargetOrg     string
+}
+
+func (r secretRecord) isOrgLevel() bool {
+	return r.Scope == api.EntityTypeOrg
+}
+
+// Returns the organization, repository, or repository environment the secret is synced to
+func (r secretRecord) location() string {
+	switch {
+	case r.isOrgLevel():
+		return r.TargetOrg
+	case r.Environment != "":
+		return r.TargetOrg + "/" + r.Scope + ":" + r.Environment
+	}
+	return r.TargetOrg + "/" + r.Scope
+}
+
+// secretValues holds the secret values supplied by the operator, keyed by store, scope, environment, and name
+type secretValues map[string]string
+
+func secretValueKey(store, scope, env, name string) string {
+	return store + "\x00" + scope + "\x00" + env + "\x00" + name
+}
+
+// Looks up the value for a secret in the values file, preferring exact scope matches over
+// name-only rows and store-specific rows over rows for any store, then falls back to the
+// GHMV_<STORE>_SECRET_<NAME> and GHMV_SECRET_<NAME> environment variables
+func (v secretValues) lookup(record secretRecord) (string, bool) {
+	candidates := []string{
+		secretValueKey(record.Store, record.Scope, record.Environment, record.Name),
+		secretValueKey("", record.Scope, record.Environment, record.Name),
+		secretValueKey(record.Store, "", "", record.Name),
+		secretValueKey("", "", "", record.Name),
+	}
+	for _, key := range candidates {
+		if value, ok := v[key]; ok {
+			return value, true
+		}
+	}
+	if value, ok := os.LookupEnv("GHMV_" + strings.ToUpper(record.Store) + "_SECRET_" + record.Name); ok {
+		return value, true
+	}
+	return os.LookupEnv(secretValueEnvPrefix + record.Name)
+}
+
+// Reads secret records from an exported secrets inventory CSV file
+func readSecretRecords(inputFile string) ([]secretRecord, int, error) {
+	file, err := os.Open(inputFile)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot open file %s: %v", inputFile, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read file %s: %v", inputFile, err)
+	}
+	if len(rows) == 0 {
+		return nil, 0, fmt.Errorf("file %s is empty", inputFile)
+	}
+
+	var records []secretRecord
+	skipped := 0
+	// Skip header row and process secrets
+	for _, row := range rows[1:] {
+		if len(row) < 6 || row[0] == "" || row[1] == "" {
+			pterm.Warning.Printf("Warning: secret record %v does not have enough columns. Skipping...\n", row)
+			skipped++
+			continue
+		}
+
+		record := secretRecord{
+			Store:       api.SecretStoreActions,
+			Name:        row[0],
+			Scope:       row[1],
+			Environment: row[2],
+			Visibility:  row[3],
+			SourceOrg:   row[5],
+		}
+		if row[4] != "" {
+			record.SelectedRepos = strings.Split(row[4], api.SelectedReposSeparator)
+		}
+		// Inventories from older versions only contain Actions secrets
+		if len(row) > 6 && row[6] != "" {
+			record.Store = row[6]
+		}
+		records = append(records, record)
+	}
+
+	return records, skipped, nil
+}
+
+// Reads secret values from a CSV file with Name,Scope,Environment,Value and an optional Store column.
+// Rows with an empty Scope apply to every secret with that name, and rows without a Store to every store.
+func loadSecretValues(path string) (secretValues, error) {
+	values := make(secretValues)
+	if path == "" {
+		return values, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open secret values file %s: %v", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read secret values file %s: %v", path, err)
+	}
+
+	// Skip header row and load each value
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		if len(row) < 4 || row[0] == "" {
+			return nil, fmt.Errorf("secret values file %s line %d: expected Name,Scope,Environment,Value", path, i+1)
+		}
+		store := ""
+		if len(row) > 4 {
+			store = row[4]
+		}
+		values[secretValueKey(store, row[1], row[2], row[0])] = row[3]
+	}
+	return values, nil
+}
+
+// Routes secret records to their target organizations. Organization secrets are copied to every
+// target organization that receives repositories from their source organization.
+func routeSecretRecords(records []secretRecord, mapping *orgMapping) ([]secretRecord, error) {
+	var routed []secretRecord
+	for _, record := range records {
+		if record.isOrgLevel() {
+			targets := mapping.targetsForOrg(record.SourceOrg)
+			if len(targets) == 0 {
+				return nil, fmt.Errorf("no target organization for organization secret %s from %q", record.Name, record.SourceOrg)
+			}
+			for _, target := range targets {
+				record.TargetOrg = target
+				routed = append(routed, record)
+			}
+			continue
+		}
+		record.TargetOrg = mapping.targetFor(record.SourceOrg, record.Scope)
+		if record.TargetOrg == "" {
+			return nil, fmt.Errorf("no target organization for repository %s from %q", record.Scope, record.SourceOrg)
+		}
+		routed = append(routed, record)
+	}
+	return routed, nil
+}
+
+// Creates or updates a single secret in the target, encrypting its value with the target's public key
+func syncSecret(record secretRecord, value, token, hostname string) error {
+	switch {
+	case record.isOrgLevel():
+		return api.SetOrgSecret(record.Store, record.TargetOrg, record.Name, value, record.Visibility, record.SelectedRepos, token, hostname)
+	case record.Environment != "":
+		if err := api.EnsureEnvironment(record.TargetOrg, record.Scope, record.Environment, token, hostname); err != nil {
+			return err
+		}
+		return api.SetEnvSecret(record.TargetOrg, record.Scope, record.Environment, record.Name, value, token, hostname)
+	}
+	return api.SetRepoSecret(record.Store, record.TargetOrg, record.Scope, record.Name, value, token, hostname)
+}
+
+// secretStats summarizes the outcome of syncing secrets
+type secretStats struct {
+	total   int
+	synced  int
+	failed  int
+	skipped int
+	// Secrets without a supplied value, by secret store
+	missing map[string][]string
+}
+
+// Returns the number of secrets without a supplied value across every store
+func (s secretStats) missingCount() int {
+	count := 0
+	for _, missing := range s.missing {
+		count += len(missing)
+	}
+	return count
+}
+
+// secretPlan holds the secrets of an inventory file routed to their target organizations, and
+// the values supplied for them
+type secretPlan struct {
+	records []secretRecord
+	skipped int
+	values  secretValues
+}
+
+// Reads the secrets inventory and the secret values and routes every secret, so that a bad file
+// or an unroutable secret stops the sync before any variable is written
+func loadSecretPlan(inventoryFile, valuesFile string, mapping *orgMapping) (*secretPlan, error) {
+	records, skipped, err := readSecretRecords(inventoryFile)
+	if err != nil {
+		return nil, err
+	}
+	values, err := loadSecretValues(valuesFile)
+	if err != nil {
+		return nil, err
+	}
+	records, err = routeSecretRecords(records, mapping)
+	if err != nil {
+		return nil, err
+	}
+	return &secretPlan{records: records, skipped: skipped, values: values}, nil
+}
+
+// Syncs the planned secrets to their target organizations. Secrets without a supplied value are
+// reported as missing and left untouched.
+func syncSecrets(plan *secretPlan, capabilities *api.Capabilities, archived *archivedRepos, token, hostname string, dryRun bool) secretStats {
+	stats := secretStats{missing: make(map[string][]string)}
+	stats.total += plan.skipped
+	stats.skipped += plan.skipped
+
+	for _, record := range plan.records {
+		stats.total++
+
+		// Stores the target instance does not support were reported by the preflight check
+		if !capabilities.Supports(api.SecretStoreFeature(record.Store)) {
+			stats.skipped++
+			continue
+		}
+
+		// Secrets follow the archived policy like variables, an archived repository rejects writes
+		if !archived.writable(record.TargetOrg, record.Scope) {
+			pterm.Warning.Printf("Skipping %s secret %s: repository %s/%s is archived\n", record.Store, record.Name, record.TargetOrg, record.Scope)
+			stats.skipped++
+			continue
+		}
+
+		value, ok := plan.values.lookup(record)
+		if !ok {
+			pterm.Warning.Printf("No value supplied for %s secret %s in %s. Skipping...\n", record.Store, record.Name, record.location())
+			stats.missing[record.Store] = append(stats.missing[record.Store], record.location()+" "+record.Name)
+			continue
+		}
+
+		if dryRun {
+			pterm.Info.Printf("Would set %s secret %s in %s\n", record.Store, record.Name, record.location())
+			stats.synced++
+			continue
+		}
+		if err := syncSecret(record, value, token, hostname); err != nil {
+			pterm.Error.Printf("Error setting %s secret %s in %s: %v\n", record.Store, record.Name, record.location(), err)
+			stats.failed++
+			continue
+		}
+		pterm.Success.Printf("Set %s secret %s in %s\n", record.Store, record.Name, record.location())
+		stats.synced++
+	}
+
+	return stats
+}
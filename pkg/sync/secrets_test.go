@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+)
+
+func TestSecretValueLookup(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret_values.csv")
+	content := "Name,Scope,Environment,Value,Store\n" +
+		"DEPLOY_KEY,,,any-scope,\n" +
+		"DEPLOY_KEY,,,any-scope-dependabot,dependabot\n" +
+		"DEPLOY_KEY,app,,app,\n" +
+		"DEPLOY_KEY,app,,app-dependabot,dependabot\n" +
+		"DEPLOY_KEY,app,staging,app-staging\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	values, err := loadSecretValues(file)
+	if err != nil {
+		t.Fatalf("loadSecretValues: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		record secretRecord
+		env    map[string]string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "exact scope and store",
+			record: secretRecord{Store: api.SecretStoreDependabot, Name: "DEPLOY_KEY", Scope: "app"},
+			want:   "app-dependabot",
+			wantOK: true,
+		},
+		{
+			name:   "exact scope for any store",
+			record: secretRecord{Store: api.SecretStoreActions, Name: "DEPLOY_KEY", Scope: "app"},
+			want:   "app",
+			wantOK: true,
+		},
+		{
+			name:   "environment",
+			record: secretRecord{Store: api.SecretStoreActions, Name: "DEPLOY_KEY", Scope: "app", Environment: "staging"},
+			want:   "app-staging",
+			wantOK: true,
+		},
+		{
+			name:   "name only for the store beats name only for any store",
+			record: secretRecord{Store: api.SecretStoreDependabot, Name: "DEPLOY_KEY", Scope: "web"},
+			want:   "any-scope-dependabot",
+			wantOK: true,
+		},
+		{
+			name:   "name only for any store",
+			record: secretRecord{Store: api.SecretStoreCodespaces, Name: "DEPLOY_KEY", Scope: "web"},
+			env:    map[string]string{"GHMV_SECRET_DEPLOY_KEY": "from-env"},
+			want:   "any-scope",
+			wantOK: true,
+		},
+		{
+			name:   "store-specific environment variable",
+			record: secretRecord{Store: api.SecretStoreDependabot, Name: "API_KEY", Scope: "app"},
+			env:    map[string]string{"GHMV_DEPENDABOT_SECRET_API_KEY": "store-env", "GHMV_SECRET_API_KEY": "env"},
+			want:   "store-env",
+			wantOK: true,
+		},
+		{
+			name:   "environment variable",
+			record: secretRecord{Store: api.SecretStoreActions, Name: "API_KEY", Scope: "app"},
+			env:    map[string]string{"GHMV_SECRET_API_KEY": "env"},
+			want:   "env",
+			wantOK: true,
+		},
+		{
+			name:   "no value",
+			record: secretRecord{Store: api.SecretStoreActions, Name: "API_KEY", Scope: "app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			value, ok := values.lookup(tt.record)
+			if value != tt.want || ok != tt.wantOK {
+				t.Fatalf("lookup() = %q, %v, want %q, %v", value, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLoadSecretValuesRejectsShortRows(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret_values.csv")
+	if err := os.WriteFile(file, []byte("Name,Scope,Environment,Value\nDEPLOY_KEY,app\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSecretValues(file); err == nil {
+		t.Fatal("loadSecretValues() accepted a row without a value")
+	}
+}
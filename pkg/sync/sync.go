@@ -3,38 +3,225 @@ package sync
 import (
 	"encoding/csv"
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/mona-actions/gh-migrate-variables/internal/api"
+	"github.com/mona-actions/gh-migrate-variables/internal/logging"
+	"github.com/mona-actions/gh-migrate-variables/pkg/journal"
+	"github.com/mona-actions/gh-migrate-variables/pkg/output"
+	"github.com/mona-actions/gh-migrate-variables/pkg/preflight"
+	"github.com/mona-actions/gh-migrate-variables/pkg/secretscan"
+	"github.com/mona-actions/gh-migrate-variables/pkg/wizard"
 	"github.com/pterm/pterm"
 	"github.com/spf13/viper"
 )
 
+const defaultVisibility = "private"
+
+// variableRecord is a single variable read from the input CSV and routed to a target organization
+type variableRecord struct {
+	Name       string
+	Value      string
+	Scope      string
+	Visibility string
+	SourceOrg  string
+	TargetOrg  string
+	// SelectedRepos are the source repositories an organization variable with selected visibility is available to
+	SelectedRepos []string
+}
+
+func (r variableRecord) isOrgLevel() bool {
+	return r.Scope == api.EntityTypeOrg
+}
+
+// Returns the entity type the record is synced to, for messages
+func (r variableRecord) scopeLabel() string {
+	if r.isOrgLevel() {
+		return api.EntityTypeOrg
+	}
+	return api.EntityTypeRepository
+}
+
+// Returns the organization, or organization/repository, the record is synced to
+func (r variableRecord) location() string {
+	if r.isOrgLevel() {
+		return r.TargetOrg
+	}
+	return r.TargetOrg + "/" + r.Scope
+}
+
+const (
+	outcomeCreated     = "created"
+	outcomeUpdated     = "updated"
+	outcomeUnchanged   = "unchanged"
+	outcomeMissingRepo = "missing-repo"
+)
+
+// Creates or updates a single variable in the target and records the change in the journal
+// In dry-run mode the outcome is determined without writing anything.
+func syncRecord(record variableRecord, token, hostname string, journalWriter *journal.Writer, dryRun bool) (string, error) {
+	visibility := record.Visibility
+	if visibility == "" {
+		visibility = defaultVisibility
+	}
+
+	var existing map[string]string
+	var err error
+	if record.isOrgLevel() {
+		existing, err = api.GetOrgVariable(record.TargetOrg, record.Name, token, hostname)
+	} else {
+		existing, err = api.GetRepoVariable(record.TargetOrg, record.Scope, record.Name, token, hostname)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Organization variables with selected visibility are only visible to the selected repositories
+	// that exist in the target
+	setSelected := record.isOrgLevel() && visibility == "selected"
+	var selected []string
+	if setSelected {
+		catalog, err := api.FetchRepoCatalog(record.TargetOrg, token, hostname)
+		if err != nil {
+			return "", err
+		}
+		var missing []string
+		selected, missing = splitSelectedRepos(record.SelectedRepos, catalog)
+		if len(missing) > 0 {
+			output.Warning("Organization variable %s in %s: selected repositories missing in the target are left out: %s", record.Name, record.TargetOrg, strings.Join(missing, ", "))
+		}
+	}
+
+	entry := journal.Entry{
+		Hostname:     hostname,
+		Organization: record.TargetOrg,
+		Name:         record.Name,
+		Value:        record.Value,
+		Visibility:   visibility,
+	}
+	if !record.isOrgLevel() {
+		entry.Repository = record.Scope
+	}
+
+	outcome := outcomeCreated
+	if existing == nil {
+		if dryRun {
+			return outcomeCreated, nil
+		}
+		if record.isOrgLevel() {
+			err = api.AddOrgVariable(record.TargetOrg, record.Name, record.Value, visibility, token, hostname)
+		} else {
+			err = api.AddRepoVariable(record.TargetOrg, record.Scope, record.Name, record.Value, visibility, token, hostname)
+			// Check if the error is due to missing repository
+			if err != nil && err.Error() == fmt.Sprintf("repository %s does not exist in organization %s", record.Scope, record.TargetOrg) {
+				return outcomeMissingRepo, err
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		entry.Action = journal.ActionCreated
+	} else {
+		// The selected repositories are replaced or lost by the update, so they are journaled for rollback
+		if record.isOrgLevel() && existing["Visibility"] == "selected" {
+			entry.PreviousSelectedRepos, err = api.FetchSelectedReposForOrgVariable(record.TargetOrg, record.Name, token, hostname)
+			if err != nil {
+				return "", err
+			}
+		}
+		// Repository variables have no visibility of their own, so only the value is compared for them
+		if existing["Value"] == record.Value && (!record.isOrgLevel() || existing["Visibility"] == visibility) &&
+			(!setSelected || sameRepos(entry.PreviousSelectedRepos, selected)) {
+			return outcomeUnchanged, nil
+		}
+		if dryRun {
+			return outcomeUpdated, nil
+		}
+		if record.isOrgLevel() {
+			err = api.UpdateOrgVariable(record.TargetOrg, record.Name, record.Value, visibility, token, hostname)
+		} else {
+			err = api.UpdateRepoVariable(record.TargetOrg, record.Scope, record.Name, record.Value, visibility, token, hostname)
+		}
+		if err != nil {
+			return "", err
+		}
+		entry.Action = journal.ActionUpdated
+		entry.PreviousValue = existing["Value"]
+		entry.PreviousVisibility = existing["Visibility"]
+		outcome = outcomeUpdated
+	}
+
+	// Selected repositories are managed separately from the variable itself
+	if setSelected {
+		if err := api.SetSelectedReposForOrgVariable(record.TargetOrg, record.Name, selected, token, hostname); err != nil {
+			// The variable itself was written, so it is still journaled for rollback
+			if journalErr := journalWriter.Record(entry); journalErr != nil {
+				return "", fmt.Errorf("%w, and the variable could not be journaled: %v", err, journalErr)
+			}
+			return "", err
+		}
+	}
+
+	if err := journalWriter.Record(entry); err != nil {
+		return "", fmt.Errorf("variable was synced but could not be journaled: %w", err)
+	}
+	return outcome, nil
+}
+
 // SyncVariables handles the syncing of variables from a CSV file to a target organization
 func SyncVariables() error {
 	start := time.Now()
-	spinner, _ := pterm.DefaultSpinner.Start("Sync finished...")
 
 	inputFile := viper.GetString("file")
 	hostname := viper.GetString("target-hostname")
 	targetOrg := viper.GetString("target-organization")
 	targetToken := viper.GetString("target-token")
-
-	if inputFile == "" || targetOrg == "" || targetToken == "" {
-		return fmt.Errorf("missing required parameters: mapping file, target organization, or target token")
+	mappingFile := viper.GetString("mapping-file")
+	collisionPolicy := viper.GetString("collision-policy")
+	if collisionPolicy == "" {
+		collisionPolicy = CollisionPolicyFail
 	}
+	if err := validateCollisionPolicy(collisionPolicy); err != nil {
+		return err
+	}
+	dryRun := viper.GetBool("GHMV_DRY_RUN")
+	prune := viper.GetBool("GHMV_PRUNE")
+	assumeYes := viper.GetBool("GHMV_YES")
+	secretsFile := viper.GetString("secrets-file")
 
-	file, err := os.Open(inputFile)
+	if err := wizard.Check(); err != nil {
+		return err
+	}
+	scanner, err := secretscan.New(viper.GetString("secret-scan-policy"), strings.Split(viper.GetString("secret-scan-ignore"), ","))
 	if err != nil {
-		return fmt.Errorf("cannot open file %s: %v", inputFile, err)
+		return err
+	}
+	if targetOrg == "" && mappingFile == "" && targetToken != "" && wizard.Enabled() {
+		selected, err := selectTargetOrganization(targetToken, hostname)
+		if err != nil {
+			return err
+		}
+		targetOrg = selected
+		viper.Set("target-organization", targetOrg)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	if inputFile == "" || targetToken == "" || (targetOrg == "" && mappingFile == "") {
+		return fmt.Errorf("missing required parameters: mapping file, target organization, or target token")
+	}
+
+	// Check that the target instance supports what the sync needs before any work starts
+	var optionalFeatures []string
+	if secretsFile != "" {
+		for _, store := range []string{api.SecretStoreActions, api.SecretStoreDependabot, api.SecretStoreCodespaces} {
+			optionalFeatures = append(optionalFeatures, api.SecretStoreFeature(store))
+		}
+	}
+	capabilities, err := preflight.Check(targetToken, hostname, []string{api.FeatureActionsVariables}, optionalFeatures)
 	if err != nil {
-		return fmt.Errorf("cannot read file %s: %v", inputFile, err)
+		return err
 	}
 
 	var stats struct {
@@ -42,70 +229,467 @@ func SyncVariables() error {
 		succeeded int
 		failed    int
 		skipped   int
+		updated   int
+		unchanged int
+		pruned    int
+		archived  int
+		queued    int
 	}
 
-	// Skip header row and process variables
-	for _, record := range records[1:] {
-		stats.total++
+	records, skipped, err := readVariableRecords(inputFile)
+	if err != nil {
+		return err
+	}
+	stats.total += skipped
+	stats.skipped += skipped
 
-		if len(record) < 4 {
-			pterm.Warning.Printf("Warning: record %v does not have enough columns. Skipping...\n", record)
-			stats.skipped++
-			continue
+	// Route every record to its target organization and resolve org-level collisions
+	mapping, err := loadOrgMapping(mappingFile, targetOrg)
+	if err != nil {
+		return err
+	}
+	records, err = routeRecords(records, mapping)
+	if err != nil {
+		return err
+	}
+	records, skipped, err = resolveCollisions(records, mapping, collisionPolicy, targetToken, hostname)
+	if err != nil {
+		return err
+	}
+	stats.total += skipped
+	stats.skipped += skipped
+
+	var secretsPlan *secretPlan
+	if secretsFile != "" {
+		secretsPlan, err = loadSecretPlan(secretsFile, viper.GetString("secret-values"), mapping)
+		if err != nil {
+			return err
 		}
+	}
 
-		variableName := record[0]
-		variableValue := record[1]
-		scope := record[2]
-		visibility := record[3]
+	// In interactive mode the operator narrows down what is synced. Prune stays limited to the
+	// selected locations but still spares variables that are in the input file.
+	fileRecords := records
+	if wizard.Enabled() {
+		fileRecords, records, err = selectRecords(records)
+		if err != nil {
+			return err
+		}
+	}
 
-		pterm.Info.Printf("Syncing variable - Name: %s, Value: %s, Scope: %s, Visibility: %s\n",
-			variableName, variableValue, scope, visibility)
+	// Check every value for credentials before anything is written. Blocked variables stay in
+	// fileRecords, so prune does not delete their target counterparts.
+	records, withheld := scanRecords(scanner, records)
+	scanner.Report()
+	stats.total += scanner.Blocked() + withheld
+	stats.skipped += withheld
 
-		if scope == "organization" {
-			err := api.AddOrgVariable(targetOrg, variableName, variableValue, visibility, targetToken, hostname)
+	// Check that the token can write to every target organization before changing anything
+	if err := preflight.CheckAccess(preflight.Endpoint{
+		Label:         "Target",
+		Token:         targetToken,
+		Hostname:      hostname,
+		Organizations: targetOrganizations(records),
+		Access:        preflight.AccessWrite,
+		OrgWrite:      writesOrgLevel(fileRecords, secretsPlan),
+	}); err != nil {
+		return err
+	}
+
+	// Find target variables that are absent from the input file and confirm their deletion up front
+	var pruneCandidates []pruneCandidate
+	if prune {
+		pterm.Info.Println("Looking for target variables that are not in the input file...")
+		pruneCandidates, err = findPruneCandidates(fileRecords, targetToken, hostname)
+		if err != nil {
+			return err
+		}
+		// The interactive plan confirms the deletions together with everything else
+		if len(pruneCandidates) > 0 && !dryRun && !assumeYes && !wizard.Enabled() {
+			confirmed, err := confirmPrune(pruneCandidates)
 			if err != nil {
-				pterm.Error.Printf("Error adding organization variable %s: %v\n", variableName, err)
-				stats.failed++
-			} else {
-				pterm.Success.Printf("Added organization variable: %s\n", variableName)
-				stats.succeeded++
+				return err
 			}
-		} else {
-			err := api.AddRepoVariable(targetOrg, scope, variableName, variableValue, visibility, targetToken, hostname)
-			if err != nil {
-				// Check if the error is due to missing repository
-				if err.Error() == fmt.Sprintf("repository %s does not exist in organization %s", scope, targetOrg) {
-					pterm.Warning.Printf("Skipping variable %s: %v\n", variableName, err)
-					stats.skipped++
-				} else {
-					pterm.Error.Printf("Error adding repository variable %s: %v\n", variableName, err)
-					stats.failed++
-				}
-			} else {
-				pterm.Success.Printf("Added repository variable: %s in %s\n", variableName, scope)
-				stats.succeeded++
+			if !confirmed {
+				return fmt.Errorf("sync cancelled, no variables were changed")
 			}
 		}
 	}
-	if stats.failed > 0 {
-		spinner.Warning("Some variables failed to sync")
+
+	// Build the repository catalog of every target organization once, so repository existence is checked locally
+	targets := repoTargets(records, pruneCandidates, secretsPlan)
+	catalogs, err := fetchTargetCatalogs(targets, targetToken, hostname)
+	if err != nil {
+		return err
+	}
+	archived, err := newArchivedRepos(viper.GetString("archived-policy"), catalogs, targetToken, hostname, dryRun)
+	if err != nil {
+		return err
+	}
+	if err := archived.check(targets); err != nil {
+		return err
+	}
+	// Unarchived repositories are archived again even when the sync stops early or is interrupted
+	defer archived.restore()
+	stopWatching := archived.restoreOnInterrupt()
+	defer stopWatching()
+
+	// Variables for repositories that do not exist yet are queued for a later sync --pending run
+	pending, err := loadPendingQueue(pendingQueuePath(targetOrg))
+	if err != nil {
+		return err
+	}
+
+	if wizard.Enabled() {
+		confirmed, err := confirmPlan(records, pruneCandidates, dryRun)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("sync cancelled, no variables were changed")
+		}
+	}
+
+	output.Group("Syncing variables")
+	progress := output.StartProgress("Syncing variables", len(records)+len(pruneCandidates))
+
+	// Record every change so that a rollback can reverse this sync later
+	var journalWriter *journal.Writer
+	journalFile := viper.GetString("journal-file")
+	if journalFile == "" {
+		journalFile = journal.DefaultPath(targetOrg, start)
+	}
+	if !dryRun {
+		journalWriter, err = journal.Create(journalFile)
+		if err != nil {
+			progress.Stop()
+			return err
+		}
+	}
+
+	for _, record := range records {
+		stats.total++
+
+		output.Detail(pterm.Info, "Syncing variable - Name: %s, Value: %s, Scope: %s, Visibility: %s, Target: %s\n",
+			record.Name, record.Value, record.Scope, record.Visibility, record.TargetOrg)
+
+		if !record.isOrgLevel() && !catalogs[record.TargetOrg].Exists(record.Scope) {
+			output.Detail(pterm.Warning, "Queueing variable %s: repository %s does not exist in organization %s\n", record.Name, record.Scope, record.TargetOrg)
+			pending.add(record)
+			stats.queued++
+			progress.Skipped()
+			continue
+		}
+		// Archived repositories are read-only, so they are skipped or temporarily unarchived
+		if !archived.writable(record.TargetOrg, record.Scope) {
+			output.Detail(pterm.Warning, "Skipping variable %s: repository %s is archived\n", record.Name, record.location())
+			stats.archived++
+			progress.Skipped()
+			continue
+		}
+
+		outcome, err := syncRecord(record, targetToken, hostname, journalWriter, dryRun)
+		switch {
+		case outcome == outcomeMissingRepo:
+			output.Detail(pterm.Warning, "Queueing variable %s: %v\n", record.Name, err)
+			pending.add(record)
+			stats.queued++
+			progress.Skipped()
+		case err != nil:
+			output.Error("Error syncing %s variable %s: %v", record.scopeLabel(), record.Name, err)
+			stats.failed++
+			progress.Failed()
+		case outcome == outcomeCreated && dryRun:
+			output.Detail(pterm.Info, "Would add %s variable: %s in %s\n", record.scopeLabel(), record.Name, record.location())
+			stats.succeeded++
+			progress.Succeeded()
+		case outcome == outcomeCreated:
+			output.Detail(pterm.Success, "Added %s variable: %s in %s\n", record.scopeLabel(), record.Name, record.location())
+			stats.succeeded++
+			progress.Succeeded()
+		case outcome == outcomeUpdated && dryRun:
+			output.Detail(pterm.Info, "Would update %s variable: %s in %s\n", record.scopeLabel(), record.Name, record.location())
+			stats.updated++
+			progress.Succeeded()
+		case outcome == outcomeUpdated:
+			output.Detail(pterm.Success, "Updated %s variable: %s in %s\n", record.scopeLabel(), record.Name, record.location())
+			stats.updated++
+			progress.Succeeded()
+		case outcome == outcomeUnchanged:
+			output.Detail(pterm.Info, "Unchanged %s variable: %s in %s\n", record.scopeLabel(), record.Name, record.location())
+			stats.unchanged++
+			progress.Succeeded()
+		}
+		if err != nil && outcome != outcomeMissingRepo {
+			slog.Error("variable sync failed", "variable", record.Name, "location", record.location(), "error", err)
+			outcome = "failed"
+		} else {
+			slog.Info("variable synced", "variable", record.Name, "location", record.location(), "outcome", outcome, "dry_run", dryRun)
+		}
+		output.Event("variable", map[string]any{"name": record.Name, "location": record.location(), "outcome": outcome, "dry_run": dryRun})
+	}
+
+	for _, candidate := range pruneCandidates {
+		if !archived.writable(candidate.TargetOrg, candidate.Scope) {
+			output.Detail(pterm.Warning, "Not deleting variable %s: repository %s is archived\n", candidate.Name, candidate.location())
+			stats.archived++
+			progress.Skipped()
+			continue
+		}
+		if dryRun {
+			output.Detail(pterm.Info, "Would delete variable %s from %s\n", candidate.Name, candidate.location())
+			stats.pruned++
+			progress.Succeeded()
+			continue
+		}
+		if err := pruneVariable(candidate, targetToken, hostname, journalWriter); err != nil {
+			output.Error("Error deleting variable %s from %s: %v", candidate.Name, candidate.location(), err)
+			stats.failed++
+			progress.Failed()
+			continue
+		}
+		output.Detail(pterm.Success, "Deleted variable %s from %s\n", candidate.Name, candidate.location())
+		stats.pruned++
+		progress.Succeeded()
+	}
+	progress.Stop()
+
+	// Secrets are written encrypted and cannot be read back, so they are not journaled
+	var secrets secretStats
+	if secretsPlan != nil {
+		secrets = syncSecrets(secretsPlan, capabilities, archived, targetToken, hostname, dryRun)
+	}
+
+	stats.failed += archived.restore()
+
+	if stats.queued > 0 && !dryRun {
+		if err := pending.save(); err != nil {
+			pterm.Error.Printf("Failed to write pending file: %v\n", err)
+			stats.failed++
+		}
+	}
+
+	journalEntries := 0
+	if journalWriter != nil {
+		journalEntries = journalWriter.Entries()
+		if err := journalWriter.Close(); err != nil {
+			pterm.Warning.Printf("Failed to close journal file %s: %v\n", journalFile, err)
+		}
+	}
+
+	if stats.failed > 0 || secrets.failed > 0 {
+		pterm.Warning.Println("Some variables failed to sync")
 	} else {
-		spinner.Success()
+		pterm.Success.Println("Sync finished")
 	}
 
-	fmt.Printf("\n📊 Sync Summary:\n")
-	fmt.Printf("Total variables processed: %d\n", stats.total)
-	fmt.Printf("✅ Successfully created: %d\n", stats.succeeded)
-	fmt.Printf("❌ Failed: %d\n", stats.failed)
-	fmt.Printf("🚧 Skipped: %d\n", stats.skipped)
-	fmt.Printf("🕐 Total time: %v\n", time.Since(start).Round(time.Second))
+	summary := output.NewSummary("sync", "Sync Summary")
+	summary.Add("", "Total variables processed", "total", stats.total)
+	summary.Add("✅", "Successfully created", "created", stats.succeeded)
+	summary.Add("🔄", "Updated", "updated", stats.updated)
+	summary.Add("⏸️ ", "Unchanged", "unchanged", stats.unchanged)
+	summary.Add("❌", "Failed", "failed", stats.failed)
+	summary.Add("🚧", "Skipped", "skipped", stats.skipped)
+	if stats.archived > 0 {
+		summary.Add("🗄️ ", "Skipped (archived repository)", "archived", stats.archived)
+	}
+	if stats.queued > 0 {
+		summary.Add("⏳", "Queued (missing repository)", "queued", stats.queued)
+		if !dryRun {
+			summary.Add("📁", "Pending file", "pending_file", pending.path+", replay it with sync --pending once the repositories exist")
+		}
+	}
+	if prune {
+		summary.Add("🗑️ ", "Pruned", "pruned", stats.pruned)
+	}
+	if secretsFile != "" {
+		summary.Add("🔐", "Secrets processed", "secrets_total", secrets.total)
+		summary.Add("🔐", "Secrets set", "secrets_set", secrets.synced)
+		summary.Add("🔐", "Secrets failed", "secrets_failed", secrets.failed)
+		summary.Add("🔐", "Secrets skipped", "secrets_skipped", secrets.skipped)
+		summary.Add("🔐", "Secrets missing values", "secrets_missing", secrets.missingCount())
+		for _, store := range []string{api.SecretStoreActions, api.SecretStoreDependabot, api.SecretStoreCodespaces} {
+			if len(secrets.missing[store]) == 0 {
+				continue
+			}
+			summary.AddList("⚠️ ", fmt.Sprintf("Missing %s secret values", store), "missing_"+store+"_secrets", secrets.missing[store])
+		}
+	}
+	scanner.Summarize(summary)
+	if journalEntries > 0 {
+		summary.Add("📒", "Journal file", "journal_file", journalFile)
+	}
+	summary.Add("🕐", "Total time", "duration", time.Since(start).Round(time.Second).String())
+
+	if stats.failed > 0 || secrets.failed > 0 {
+		summary.Print(output.StatusFailed)
+		output.Failed(fmt.Sprintf("sync completed with %d failed variables and %d failed secrets", stats.failed, secrets.failed))
+		logging.Exit(1)
+	}
+	if scanner.Blocked() > 0 {
+		summary.Print(output.StatusFailed)
+		output.Failed(fmt.Sprintf("sync skipped %d variables that look like credentials, store them as secrets or exclude them with --secret-scan-ignore", scanner.Blocked()))
+		logging.Exit(1)
+	}
 
-	if stats.failed > 0 {
-		fmt.Printf("\n🛑 sync completed with %d failed variables\n", stats.failed)
-		os.Exit(1)
+	if dryRun {
+		summary.Print(output.StatusDryRun)
+		output.Message("🔍", "Dry run: no changes were made.")
+		return nil
 	}
 
-	fmt.Println("\n✅ Sync completed successfully!")
+	summary.Print(output.StatusSuccess)
+	output.Message("✅", "Sync completed successfully!")
 	return nil
 }
+
+// Splits the selected repositories of an organization variable into those that exist in the
+// target catalog, with the target's spelling of their names, and those that are missing
+func splitSelectedRepos(repos []string, catalog *api.RepoCatalog) ([]string, []string) {
+	var present, missing []string
+	for _, repo := range repos {
+		if info, ok := catalog.Lookup(repo); ok {
+			present = append(present, info.Name)
+		} else {
+			missing = append(missing, repo)
+		}
+	}
+	return present, missing
+}
+
+// Reports whether two lists name the same repositories, ignoring order and case
+func sameRepos(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, repo := range a {
+		counts[strings.ToLower(repo)]++
+	}
+	for _, repo := range b {
+		key := strings.ToLower(repo)
+		if counts[key] == 0 {
+			return false
+		}
+		counts[key]--
+	}
+	return true
+}
+
+// Checks the values of the records for credentials and drops the records the policy blocks. The
+// placeholders of values the export withheld are dropped too, and their number is returned.
+func scanRecords(scanner *secretscan.Scanner, records []variableRecord) ([]variableRecord, int) {
+	var kept []variableRecord
+	withheld := 0
+	for _, record := range records {
+		// Values withheld by the export secret scan are placeholders and are never written
+		if record.Value == secretscan.WithheldValue {
+			output.Warning("Not syncing variable %s in %s: its value was withheld by the export secret scan, create it as a secret instead", record.Name, record.location())
+			withheld++
+			continue
+		}
+		repository := ""
+		if !record.isOrgLevel() {
+			repository = record.Scope
+		}
+		if scanner.Check(record.Name, record.TargetOrg, repository, "", record.Value) {
+			kept = append(kept, record)
+		}
+	}
+	return kept, withheld
+}
+
+// Returns the distinct target organizations of the records, in order of first appearance
+func targetOrganizations(records []variableRecord) []string {
+	seen := make(map[string]bool)
+	var organizations []string
+	for _, record := range records {
+		if !seen[record.TargetOrg] {
+			seen[record.TargetOrg] = true
+			organizations = append(organizations, record.TargetOrg)
+		}
+	}
+	return organizations
+}
+
+// Reports whether a sync writes or prunes organization variables or secrets
+func writesOrgLevel(records []variableRecord, secrets *secretPlan) bool {
+	for _, record := range records {
+		if record.isOrgLevel() {
+			return true
+		}
+	}
+	if secrets != nil {
+		for _, record := range secrets.records {
+			if record.isOrgLevel() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Builds the repository catalog of every target organization whose repositories are written to
+func fetchTargetCatalogs(targets []repoTarget, token, hostname string) (map[string]*api.RepoCatalog, error) {
+	catalogs := make(map[string]*api.RepoCatalog)
+	for _, target := range targets {
+		if catalogs[target.org] != nil {
+			continue
+		}
+		pterm.Info.Printf("Fetching repository catalog for %s...\n", target.org)
+		catalog, err := api.FetchRepoCatalog(target.org, token, hostname)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch repositories for %s: %w", target.org, err)
+		}
+		pterm.Info.Printf("Found %d repositories in %s\n", len(catalog.Repositories), target.org)
+		catalogs[target.org] = catalog
+	}
+	return catalogs, nil
+}
+
+// Reads variable records from an exported CSV file, returning the number of malformed rows skipped
+func readVariableRecords(inputFile string) ([]variableRecord, int, error) {
+	file, err := os.Open(inputFile)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot open file %s: %v", inputFile, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read file %s: %v", inputFile, err)
+	}
+	if len(rows) == 0 {
+		return nil, 0, fmt.Errorf("file %s is empty", inputFile)
+	}
+
+	var records []variableRecord
+	skipped := 0
+	// Skip header row and process variables
+	for _, row := range rows[1:] {
+		if len(row) < 4 {
+			pterm.Warning.Printf("Warning: record %v does not have enough columns. Skipping...\n", row)
+			skipped++
+			continue
+		}
+
+		record := variableRecord{
+			Name:       row[0],
+			Value:      row[1],
+			Scope:      row[2],
+			Visibility: row[3],
+		}
+		// Exports from older versions do not carry the source organization column
+		if len(row) > 4 {
+			record.SourceOrg = row[4]
+		}
+		if len(row) > 5 && row[5] != "" {
+			record.SelectedRepos = strings.Split(row[5], api.SelectedReposSeparator)
+		}
+		records = append(records, record)
+	}
+
+	return records, skipped, nil
+}
@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-variables/internal/api"
+)
+
+func TestSplitSelectedRepos(t *testing.T) {
+	catalog := api.NewRepoCatalog("org", "", []api.Repository{{Name: "Frontend"}, {Name: "backend"}})
+	tests := []struct {
+		name        string
+		repos       []string
+		wantPresent []string
+		wantMissing []string
+	}{
+		{name: "none"},
+		{name: "all present", repos: []string{"Frontend", "backend"}, wantPresent: []string{"Frontend", "backend"}},
+		{name: "target spelling wins", repos: []string{"frontend"}, wantPresent: []string{"Frontend"}},
+		{name: "missing repositories are reported", repos: []string{"backend", "docs"}, wantPresent: []string{"backend"}, wantMissing: []string{"docs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			present, missing := splitSelectedRepos(tt.repos, catalog)
+			if !reflect.DeepEqual(present, tt.wantPresent) || !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Fatalf("got present %v missing %v, want %v and %v", present, missing, tt.wantPresent, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestSameRepos(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want bool
+	}{
+		{nil, nil, true},
+		{[]string{"a", "B"}, []string{"b", "A"}, true},
+		{[]string{"a"}, []string{"a", "b"}, false},
+		{[]string{"a", "a"}, []string{"a", "b"}, false},
+		{nil, []string{"a"}, false},
+	}
+	for _, tt := range tests {
+		if got := sameRepos(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameRepos(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package wizard
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// Multi-select lists taller than this scroll and can be filtered by typing
+const maxHeight = 15
+
+// Reports whether interactive mode was requested with --interactive
+func Enabled() bool {
+	return viper.GetBool("GHMV_INTERACTIVE")
+}
+
+// Check fails when interactive mode was requested without a terminal to prompt on
+func Check() error {
+	if Enabled() && !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("interactive mode requires a terminal")
+	}
+	return nil
+}
+
+// Prompts for a missing flag value. Tokens are masked while typed, and an empty hostname
+// selects GitHub.com.
+func PromptValue(name string) (string, error) {
+	label := strings.ReplaceAll(name, "-", " ")
+	label = strings.ToUpper(label[:1]) + label[1:]
+
+	prompt := pterm.DefaultInteractiveTextInput
+	switch {
+	case strings.HasSuffix(name, "-token"):
+		prompt = *prompt.WithMask("*")
+	case strings.HasSuffix(name, "-hostname"):
+		label += " (leave empty for GitHub.com)"
+	}
+	value, err := prompt.Show(label)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// Asks the operator to pick one of the options
+func SelectOne(label string, options []string) (string, error) {
+	if len(options) == 0 {
+		return "", fmt.Errorf("nothing to select for %s", strings.ToLower(label))
+	}
+	return pterm.DefaultInteractiveSelect.WithOptions(options).WithMaxHeight(maxHeight).Show(label)
+}
+
+// Asks the operator to pick any of the options, all of which start out selected. Picking
+// nothing is an error, since there would be nothing to migrate.
+func SelectMany(label string, options []string) ([]string, error) {
+	if len(options) <= 1 {
+		return options, nil
+	}
+	selected, err := pterm.DefaultInteractiveMultiselect.
+		WithOptions(options).
+		WithDefaultOptions(options).
+		WithMaxHeight(maxHeight).
+		WithFilter(true).
+		Show(label + " (space to toggle, enter to confirm)")
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("nothing selected, cancelled")
+	}
+	return selected, nil
+}
+
+// Asks the operator to confirm before anything is written
+func Confirm(label string) (bool, error) {
+	return pterm.DefaultInteractiveConfirm.Show(label)
+}